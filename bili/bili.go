@@ -2,11 +2,13 @@ package bili
 
 import (
 	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/metrics"
 	"bilibili-ticket-go/models/bili/api"
 	"bilibili-ticket-go/models/errors"
 	"bilibili-ticket-go/utils"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/imroc/req/v3"
 )
@@ -16,14 +18,18 @@ const model = "SM-S9080"
 // 2025/07/19 test project_id=103601
 
 type Client struct {
-	http         *req.Client
-	cookie       http.CookieJar
-	buvid        string
+	http   *req.Client
+	cookie http.CookieJar
+	buvid  string
+	// tokenMutex 保护 refreshToken 和 onRefreshTokenChange, 会话监控, 保存和退出流程在不同 goroutine 中访问
+	tokenMutex   sync.RWMutex
 	refreshToken string
 	appVersion   *api.BiliAppVersionStruct
 	infocUUID    string
 	wbi          *wbiKey
 	fingerprint  *Fingerprint
+	// onRefreshTokenChange 在 refresh token 变化后立即调用, 用于保存到配置文件
+	onRefreshTokenChange func()
 }
 
 type Fingerprint struct {
@@ -85,6 +91,14 @@ func GetNewClient(jar http.CookieJar, buvid string, rt string, fingerprint Finge
 	c.SetTLSFingerprintAndroid().
 		ImpersonateChrome()
 	c.SetCommonCookies()
+	// 记录每个接口的耗时, 失败的请求没有完整的 trace 信息, 不计入
+	c.EnableTraceAll()
+	c.OnAfterResponse(func(_ *req.Client, resp *req.Response) error {
+		if resp.Response != nil && resp.Err == nil {
+			metrics.ObserveRequest(resp.Request.URL, resp.TraceInfo().TotalTime)
+		}
+		return nil
+	})
 	c.WrapRoundTripFunc(func(rt req.RoundTripper) req.RoundTripFunc {
 		return func(req *req.Request) (resp *req.Response, err error) {
 			//Before
@@ -202,9 +216,28 @@ func (c *Client) GetFingerprint() Fingerprint {
 }
 
 func (c *Client) GetRefreshToken() string {
+	c.tokenMutex.RLock()
+	defer c.tokenMutex.RUnlock()
 	return c.refreshToken
 }
 
+// SetRefreshTokenChangeFunc 设置 refresh token 变化后的回调, 回调返回之后才会注销旧的 token, 应当同步保存
+func (c *Client) SetRefreshTokenChangeFunc(f func()) {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+	c.onRefreshTokenChange = f
+}
+
+func (c *Client) setRefreshToken(token string) {
+	c.tokenMutex.Lock()
+	c.refreshToken = token
+	f := c.onRefreshTokenChange
+	c.tokenMutex.Unlock()
+	if f != nil {
+		f()
+	}
+}
+
 func (c *Client) GetInfocUUID() string {
 	return c.infocUUID
 }
@@ -220,7 +253,7 @@ func (c *Client) GetQRLoginState(qrcodeKey string) (error, *api.VerifyQRLoginSta
 		return err, nil
 	}
 	if r.Data.Code == 0 {
-		c.refreshToken = r.Data.RefreshToken
+		c.setRefreshToken(r.Data.RefreshToken)
 		err := c.getBuvid34AndBnut()
 		if err != nil {
 			logger.Warnf("getBuvid34AndBnut() err: %v", err)
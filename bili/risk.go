@@ -47,7 +47,7 @@ func (c *Client) CheckAndUpdateCookie() (error, bool) {
 	}
 	oldCSRF := c.getCSRFFromCookie()
 	logger.Debugf("Old CSRF Token: %s", oldCSRF)
-	oldRefreshToken := c.refreshToken
+	oldRefreshToken := c.GetRefreshToken()
 	cp, err := getCorrespondPath(time.Now().UnixMilli() - 100)
 	if err != nil {
 		return err, false
@@ -62,7 +62,8 @@ func (c *Client) CheckAndUpdateCookie() (error, bool) {
 		return err, false
 	}
 	logger.Debugf("New Refresh Token: %s", newRefreshToken)
-	c.refreshToken = newRefreshToken
+	// 旧的 refresh token 马上就会失效, setRefreshToken 的回调同步保存新的 token 后才继续
+	c.setRefreshToken(newRefreshToken)
 	newCSRF := c.getCSRFFromCookie()
 	logger.Debugf("New CSRF Token: %s", newCSRF)
 	err = c.setPreviousCookieInvalid(newCSRF, oldRefreshToken)
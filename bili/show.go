@@ -6,6 +6,7 @@ import (
 	r "bilibili-ticket-go/models/bili/return"
 	"bilibili-ticket-go/models/enums"
 	"bilibili-ticket-go/models/errors"
+	"bilibili-ticket-go/utils"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -14,25 +15,25 @@ import (
 
 const frontVersion = "134" // Stored on "https://s1.hdslb.com/bfs/static/platform/static/js/vendor.4052c4899bf31668a61b.js?277f136a95f6bbe03034" -> var version = "134";
 
-func (c *Client) GetProjectInformation(projectID string) (error, *r.ProjectInformation) {
+func (c *Client) getProjectV2(projectID string) (error, *api.TicketProjectInformationStruct) {
 	res, err := c.http.R().Get(fmt.Sprintf("https://show.bilibili.com/api/ticket/project/getV2?version=%s&id=%s&project_id=%s&requestSource=pc-new", frontVersion, projectID, projectID))
 	if err != nil {
-		return err, &r.ProjectInformation{
-			ProjectID: projectID,
-			StartTime: time.Time{},
-			EndTime:   time.Time{},
-		}
+		return err, nil
 	}
 	var data api.MainApiDataRoot[api.TicketProjectInformationStruct]
 	err = res.Unmarshal(&data)
 	if err != nil {
-		return err, &r.ProjectInformation{
-			ProjectID: projectID,
-			StartTime: time.Time{},
-			EndTime:   time.Time{},
-		}
+		return err, nil
 	}
 	if err = data.CheckValid(); err != nil {
+		return err, nil
+	}
+	return nil, &data.Data
+}
+
+func (c *Client) GetProjectInformation(projectID string) (error, *r.ProjectInformation) {
+	err, data := c.getProjectV2(projectID)
+	if err != nil {
 		return err, &r.ProjectInformation{
 			ProjectID: projectID,
 			StartTime: time.Time{},
@@ -40,26 +41,120 @@ func (c *Client) GetProjectInformation(projectID string) (error, *r.ProjectInfor
 		}
 	}
 	var idbind = false
-	if data.Data.IdBind == 1 {
+	if data.IdBind == 1 {
 		idbind = true
 	}
-	return nil, &r.ProjectInformation{
+	info := &r.ProjectInformation{
 		ProjectID:       projectID,
-		StartTime:       time.Unix(data.Data.End, 0),
-		EndTime:         time.Unix(data.Data.Start, 0),
-		IsHotProject:    data.Data.HotProject,
-		IsNeedContact:   data.Data.NeedContact,
+		StartTime:       time.Unix(data.Start, 0),
+		EndTime:         time.Unix(data.End, 0),
+		IsHotProject:    data.HotProject,
+		IsNeedContact:   data.NeedContact,
 		IsForceRealName: idbind,
-		ProjectName:     data.Data.Name,
+		ProjectName:     data.Name,
+		SaleFlag:        data.SaleFlag,
+		Venue:           data.VenueInfo.Name,
+		VenueAddress:    data.VenueInfo.AddressDetail,
+		Screens:         make([]r.ScreenInformation, 0, len(data.ScreenList)),
+	}
+	for _, s := range data.ScreenList {
+		screen := r.ScreenInformation{
+			ScreenID: s.ScreenId,
+			Name:     s.Name,
+			Start:    time.Unix(s.StartTime, 0),
+			End:      time.Unix(s.EndTime, 0),
+			SaleFlag: s.SaleFlag.DisplayName,
+			Tickets:  make([]r.TicketSkuScreenID, 0, len(s.TicketList)),
+		}
+		for _, t := range s.TicketList {
+			screen.Tickets = append(screen.Tickets, convertTicketSku(s.ScreenId, data.HasPaperTicket || s.DeliveryType == api.DeliveryTypeExpress, s.ExpressFee, t))
+		}
+		info.Screens = append(info.Screens, screen)
 	}
+	return nil, info
 }
 
-func (c *Client) GetTicketSkuIDsByProjectID(projectID string) (error, []r.TicketSkuScreenID) {
-	res, err := c.http.R().Get(fmt.Sprintf("https://show.bilibili.com/api/ticket/project/getV2?version=%s&id=%s&project_id=%s&requestSource=pc-new", frontVersion, projectID, projectID))
+func convertTicketSku(screenID int64, needDelivery bool, expressFee int, t api.TicketSkuStruct) r.TicketSkuScreenID {
+	limit := t.StaticLimit.Num
+	if t.DynamicLimit.Num > 0 && (limit == 0 || t.DynamicLimit.Num < limit) {
+		limit = t.DynamicLimit.Num
+	}
+	if !needDelivery || expressFee < 0 {
+		expressFee = 0
+	}
+	return r.TicketSkuScreenID{
+		ScreenID: screenID,
+		SkuID:    t.SkuId,
+		Name:     t.ScreenName,
+		Desc:     t.Desc,
+		Price:    t.Price,
+		Flags: struct {
+			Number      int
+			DisplayName string
+		}{
+			Number:      t.SaleFlag.Number,
+			DisplayName: t.SaleFlag.DisplayName,
+		},
+		SaleStat: struct {
+			Start time.Time
+			End   time.Time
+		}{
+			Start: time.Unix(t.SaleStart, 0),
+			End:   time.Unix(t.SaleEnd, 0),
+		},
+		Limit:        limit,
+		NeedDelivery: needDelivery,
+		ExpressFee:   expressFee,
+	}
+}
+
+// ResolveProjectLink 解析项目 ID 或分享链接, b23.tv 短链接会先跳转到详情页再解析
+func (c *Client) ResolveProjectLink(text string) (error, utils.ProjectLink) {
+	link := text
+	if l := utils.ExtractLink(text); l != "" && utils.IsShortLink(l) {
+		res, err := c.http.R().Get(l)
+		if err != nil {
+			return err, utils.ProjectLink{}
+		}
+		if res.Response == nil || res.Response.Request == nil {
+			return fmt.Errorf("failed to resolve short link: %s", l), utils.ProjectLink{}
+		}
+		link = res.Response.Request.URL.String()
+	}
+	result, err := utils.ParseProjectLink(link)
+	return err, result
+}
+
+const projectPageSize = 20
+
+// SearchProjects 按关键字搜索项目, 关键字为空时返回全部项目列表
+// 城市和日期在接口中没有可靠的筛选参数, 由调用方通过 ProjectSummary.Match 筛选
+func (c *Client) SearchProjects(keyword string, page int) (error, *r.ProjectSearchResult) {
+	if page < 1 {
+		page = 1
+	}
+	request := c.http.R().SetQueryParams(map[string]string{
+		"version":  frontVersion,
+		"page":     strconv.Itoa(page),
+		"pagesize": strconv.Itoa(projectPageSize),
+		"platform": "web",
+	})
+	var url = "https://show.bilibili.com/api/ticket/project/listV2"
+	if keyword != "" {
+		url = "https://show.bilibili.com/api/ticket/search/list"
+		request.SetQueryParam("keyword", keyword)
+	} else {
+		request.SetQueryParams(map[string]string{
+			"area":   "-1",
+			"filter": "",
+			"p_type": "全部类型",
+		})
+	}
+	res, err := request.Get(url)
 	if err != nil {
 		return err, nil
 	}
-	var data api.MainApiDataRoot[api.TicketProjectInformationStruct]
+	var data api.ShowApiDataRoot[api.ProjectListStruct]
 	err = res.Unmarshal(&data)
 	if err != nil {
 		return err, nil
@@ -67,42 +162,61 @@ func (c *Client) GetTicketSkuIDsByProjectID(projectID string) (error, []r.Ticket
 	if err = data.CheckValid(); err != nil {
 		return err, nil
 	}
+	result := &r.ProjectSearchResult{
+		Page:     page,
+		NumPages: data.Data.NumPages,
+		Total:    data.Data.Total,
+		Projects: make([]r.ProjectSummary, 0, len(data.Data.Result)),
+	}
+	if result.NumPages == 0 && data.Data.Total > 0 {
+		result.NumPages = (data.Data.Total + projectPageSize - 1) / projectPageSize
+	}
+	for _, item := range data.Data.Result {
+		p := r.ProjectSummary{
+			ProjectID: item.ProjectId,
+			Name:      item.ProjectName,
+			City:      item.City,
+			Venue:     item.VenueName,
+			Start:     item.StartTime.Time,
+			End:       item.EndTime.Time,
+			SaleFlag:  item.SaleFlag,
+			PriceLow:  item.PriceLow,
+			PriceHigh: item.PriceHigh,
+		}
+		if p.ProjectID == 0 {
+			p.ProjectID = item.Id
+		}
+		if p.Name == "" {
+			p.Name = item.Title
+		}
+		if p.ProjectID == 0 {
+			continue
+		}
+		result.Projects = append(result.Projects, p)
+	}
+	return nil, result
+}
+
+func (c *Client) GetTicketSkuIDsByProjectID(projectID string) (error, []r.TicketSkuScreenID) {
+	err, data := c.getProjectV2(projectID)
+	if err != nil {
+		return err, nil
+	}
 	tickets := make([]r.TicketSkuScreenID, 0)
-	for _, s := range data.Data.ScreenList {
+	for _, s := range data.ScreenList {
 		for _, t := range s.TicketList {
-			ticket := r.TicketSkuScreenID{
-				ScreenID: s.ScreenId,
-				SkuID:    t.SkuId,
-				Name:     t.ScreenName,
-				Desc:     t.Desc,
-				Price:    t.Price,
-				Flags: struct {
-					Number      int
-					DisplayName string
-				}{
-					Number:      t.SaleFlag.Number,
-					DisplayName: t.SaleFlag.DisplayName,
-				},
-				SaleStat: struct {
-					Start time.Time
-					End   time.Time
-				}{
-					Start: time.Unix(t.SaleStart, 0),
-					End:   time.Unix(t.SaleEnd, 0),
-				},
-			}
-			tickets = append(tickets, ticket)
+			tickets = append(tickets, convertTicketSku(s.ScreenId, data.HasPaperTicket || s.DeliveryType == api.DeliveryTypeExpress, s.ExpressFee, t))
 		}
 	}
 	return nil, tickets
 }
 
-func (c *Client) GetRequestTokenAndPToken(tk token.Generator, projectID string, ticket r.TicketSkuScreenID) (error, *r.RequestTokenAndPToken) {
+func (c *Client) GetRequestTokenAndPToken(tk token.Generator, projectID string, ticket r.TicketSkuScreenID, count int) (error, *r.RequestTokenAndPToken) {
 	form := map[string]any{
 		"project_id":    projectID,
 		"screen_id":     ticket.ScreenID,
 		"order_type":    1,
-		"count":         1,
+		"count":         count,
 		"sku_id":        ticket.SkuID,
 		"requestSource": "pc-new",
 		"newRisk":       true,
@@ -152,22 +266,28 @@ func (c *Client) GetConfirmInformation(tokens *r.RequestTokenAndPToken, projectI
 	return nil, &data.Data
 }
 
-func (c *Client) SubmitOrder(tk token.Generator, whenGenPToken time.Time, tokens *r.RequestTokenAndPToken, projectID string, ticket r.TicketSkuScreenID, buyer interface{}, buyerType enums.BuyerType) (error, int, string, api.TicketOrderStruct) {
+// SubmitOrder 创建订单, address 为 nil 时不发送收货信息
+// 实名制项目的 buyer 为 []api.BuyerStruct, 数量必须与 count 一致; 非实名项目为联系人 map[string]string
+func (c *Client) SubmitOrder(tk token.Generator, whenGenPToken time.Time, tokens *r.RequestTokenAndPToken, projectID string, ticket r.TicketSkuScreenID, count int, buyer interface{}, buyerType enums.BuyerType, address *api.AddressStruct) (error, int, string, api.TicketOrderStruct) {
 	form := map[string]any{
 		"project_id":    projectID,
 		"screen_id":     strconv.FormatInt(ticket.ScreenID, 10),
-		"count":         "1",
-		"pay_money":     strconv.Itoa(ticket.Price),
+		"count":         strconv.Itoa(count),
+		"pay_money":     strconv.Itoa(ticket.PayMoney(count)),
 		"order_type":    "1",
 		"timestamp":     strconv.FormatInt(whenGenPToken.Unix(), 10),
 		"deviceId":      c.fingerprint.Buvidfp,
-		"click_postion": fmt.Sprintf("{\"x\":948,\"y\":997,\"origin\":%d,\"now\":%d}", whenGenPToken, time.Now().Unix()),
+		"click_postion": fmt.Sprintf("{\"x\":948,\"y\":997,\"origin\":%d,\"now\":%d}", whenGenPToken.Unix(), time.Now().Unix()),
 		"sku_id":        strconv.FormatInt(ticket.SkuID, 10),
 		"requestSource": "pc-new",
 	}
 
 	if buyerType == enums.ForceRealName {
-		bs, _ := json.Marshal(buyer)
+		buyers, ok := buyer.([]api.BuyerStruct)
+		if !ok || len(buyers) != count {
+			return errors.NewTicketEmptyContactError(projectID, strconv.FormatInt(ticket.SkuID, 10), strconv.FormatInt(ticket.ScreenID, 10)), -1, "", api.TicketOrderStruct{}
+		}
+		bs, _ := json.Marshal(buyers)
 		form["buyer_info"] = string(bs)
 	} else if buyerType == enums.Ordinary {
 		buyer := buyer.(map[string]string)
@@ -176,6 +296,15 @@ func (c *Client) SubmitOrder(tk token.Generator, whenGenPToken time.Time, tokens
 	} else {
 		return errors.NewTicketEmptyContactError(projectID, strconv.FormatInt(ticket.SkuID, 10), strconv.FormatInt(ticket.ScreenID, 10)), -1, "", api.TicketOrderStruct{}
 	}
+	if address != nil {
+		bs, _ := json.Marshal(map[string]any{
+			"name":    address.Name,
+			"tel":     address.Phone,
+			"addr_id": address.Id,
+			"addr":    address.FullAddress(),
+		})
+		form["deliver_info"] = string(bs)
+	}
 	if tk.IsHotProject() {
 		form["newRisk"] = "true"
 		form["ctoken"] = tk.GenerateTokenCreateStage(whenGenPToken)
@@ -203,6 +332,43 @@ func (c *Client) SubmitOrder(tk token.Generator, whenGenPToken time.Time, tokens
 	return nil, data.GetCode(), data.GetMessage(), data.Data
 }
 
+// GetAddressList 获取账号保存的收货地址
+func (c *Client) GetAddressList() (error, []api.AddressStruct) {
+	res, err := c.http.R().Get("https://show.bilibili.com/api/ticket/addr/list")
+	if err != nil {
+		return err, nil
+	}
+	var data api.ShowApiDataRoot[api.AddressListStruct]
+	err = res.Unmarshal(&data)
+	if err != nil {
+		return err, nil
+	}
+	if err = data.CheckValid(); err != nil {
+		return err, nil
+	}
+	return nil, data.Data.AddrList
+}
+
+// GetBuyerList 获取项目的购票人列表, 与确认页返回的 buyerList 相同, 包含一单最多的购票人数
+func (c *Client) GetBuyerList(projectID string) (error, *api.BuyerListStruct) {
+	res, err := c.http.R().SetQueryParams(map[string]string{
+		"is_default": "",
+		"projectId":  projectID,
+	}).Get("https://show.bilibili.com/api/ticket/buyer/list")
+	if err != nil {
+		return err, nil
+	}
+	var data api.ShowApiDataRoot[api.BuyerListStruct]
+	err = res.Unmarshal(&data)
+	if err != nil {
+		return err, nil
+	}
+	if err = data.CheckValid(); err != nil {
+		return err, nil
+	}
+	return nil, &data.Data
+}
+
 func (c *Client) GetBuyerNoSensitiveInfo() (error, []api.BuyerNoSensitiveStruct) {
 	query := c.getSignedParameterWithApp(map[string]any{
 		"actionKey":   "appkey",
@@ -227,3 +393,42 @@ func (c *Client) GetBuyerNoSensitiveInfo() (error, []api.BuyerNoSensitiveStruct)
 	}
 	return nil, data.Data.Vo.List
 }
+
+// AddBuyer 添加购票人, 提交后由服务端进行实名认证
+func (c *Client) AddBuyer(name string, idType int, personalID string, tel string) error {
+	res, err := c.http.R().SetFormData(map[string]string{
+		"name":        name,
+		"id_type":     strconv.Itoa(idType),
+		"personal_id": personalID,
+		"tel":         tel,
+		"is_default":  "0",
+		"src":         "ticket",
+		"csrf":        c.getCSRFFromCookie(),
+	}).Post("https://show.bilibili.com/api/ticket/buyer/create")
+	if err != nil {
+		return err
+	}
+	var data api.ShowApiDataRoot[any]
+	err = res.Unmarshal(&data)
+	if err != nil {
+		return err
+	}
+	return data.CheckValid()
+}
+
+// DeleteBuyer 删除购票人, 使用该购票人的任务将无法下单
+func (c *Client) DeleteBuyer(id int64) error {
+	res, err := c.http.R().SetFormData(map[string]string{
+		"id":   strconv.FormatInt(id, 10),
+		"csrf": c.getCSRFFromCookie(),
+	}).Post("https://show.bilibili.com/api/ticket/buyer/del")
+	if err != nil {
+		return err
+	}
+	var data api.ShowApiDataRoot[any]
+	err = res.Unmarshal(&data)
+	if err != nil {
+		return err
+	}
+	return data.CheckValid()
+}
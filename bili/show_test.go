@@ -0,0 +1,28 @@
+package bili
+
+import (
+	"bilibili-ticket-go/models/bili/api"
+	"testing"
+)
+
+func TestConvertTicketSkuExpressFee(t *testing.T) {
+	sku := api.TicketSkuStruct{SkuId: 2, Price: 38000}
+	tests := []struct {
+		name         string
+		needDelivery bool
+		expressFee   int
+		wantPay      int
+	}{
+		{"delivery order", true, 1200, 2*38000 + 1200},
+		{"no delivery", false, 1200, 2 * 38000},
+		{"fee not applicable", true, -1, 2 * 38000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := convertTicketSku(1, tt.needDelivery, tt.expressFee, sku)
+			if got := ticket.PayMoney(2); got != tt.wantPay {
+				t.Errorf("PayMoney(2) = %d, want %d", got, tt.wantPay)
+			}
+		})
+	}
+}
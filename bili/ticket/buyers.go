@@ -0,0 +1,106 @@
+package ticket
+
+import (
+	client "bilibili-ticket-go/bili"
+	"bilibili-ticket-go/models"
+	"bilibili-ticket-go/models/bili/api"
+	"bilibili-ticket-go/models/enums"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 开售前多久检查一次购票人
+const buyerCheckAhead = 10 * time.Minute
+
+// BuyerIssue 任务中已被删除或未通过实名认证的购票人
+type BuyerIssue struct {
+	ID      string
+	Ticket  models.TicketEntry
+	BuyerID int64
+	Name    string
+	Reason  string
+}
+
+func (i BuyerIssue) String() string {
+	return fmt.Sprintf("%s(%s) 购票人 %s：%s", i.Ticket.ProjectName, i.Ticket.SkuName, i.Name, i.Reason)
+}
+
+// BuyerLimit 票种限购数和项目一单最多购票人数中较小的一个, 0 表示未限制.
+// 获取项目限制失败时只使用票种限购数, 开售后确认页仍会再检查一次
+func BuyerLimit(client *client.Client, projectID int64, skuLimit int) int {
+	err, list := client.GetBuyerList(strconv.FormatInt(projectID, 10))
+	if err != nil {
+		logger.Warnf("Failed to get buyer limit of project %d: %v", projectID, err)
+		return skuLimit
+	}
+	if list.MaxLimit > 0 && (skuLimit <= 0 || list.MaxLimit < skuLimit) {
+		return list.MaxLimit
+	}
+	return skuLimit
+}
+
+// CheckBuyers 对比账号当前的购票人, 非实名项目的联系人不需要检查
+func CheckBuyers(buyers []api.BuyerNoSensitiveStruct, tickets []models.TicketEntry) []BuyerIssue {
+	issues := make([]BuyerIssue, 0)
+	for _, t := range tickets {
+		if t.Buyer.BuyerType != enums.ForceRealName {
+			continue
+		}
+		for _, want := range t.AllBuyers() {
+			issue := BuyerIssue{
+				ID:      t.ID,
+				Ticket:  t,
+				BuyerID: want.ID,
+				Name:    want.Name,
+			}
+			index := slices.IndexFunc(buyers, func(b api.BuyerNoSensitiveStruct) bool { return b.Id == want.ID })
+			if index < 0 {
+				issue.Reason = "已被删除"
+			} else if !buyers[index].Verified() {
+				issue.Reason = "未通过实名认证"
+			} else {
+				continue
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// CheckBuyers 用账号当前的购票人检查还没有结束的任务
+func (m *Manager) CheckBuyers(buyers []api.BuyerNoSensitiveStruct) []BuyerIssue {
+	tickets := make([]models.TicketEntry, 0)
+	for _, entry := range m.Entries() {
+		if !entry.Routine.State().IsFinished() {
+			tickets = append(tickets, entry.Ticket)
+		}
+	}
+	return CheckBuyers(buyers, tickets)
+}
+
+// checkBuyersBeforeSale 开售前检查购票人, 有问题时只提醒, 不会阻止任务启动
+func (m *Manager) checkBuyersBeforeSale(entry *Entry) {
+	if entry.Routine.State() != enums.StateScheduled {
+		return
+	}
+	err, buyers := m.client.GetBuyerNoSensitiveInfo()
+	if err != nil {
+		logger.Warnf("Failed to check buyers before sale[id:%s]: %v", entry.ID[:11], err)
+		return
+	}
+	issues := CheckBuyers(buyers, []models.TicketEntry{entry.Ticket})
+	if len(issues) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	logger.Warnf("Buyer problems before sale[id:%s]: %s", entry.ID[:11], strings.Join(lines, "; "))
+	if m.notify != nil {
+		m.notify.Notify("开售前购票人检查：\n" + strings.Join(lines, "\n"))
+	}
+}
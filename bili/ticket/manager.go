@@ -0,0 +1,449 @@
+package ticket
+
+import (
+	client "bilibili-ticket-go/bili"
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/models"
+	"bilibili-ticket-go/models/enums"
+	"bilibili-ticket-go/models/errors"
+	"bilibili-ticket-go/models/hooks"
+	"bilibili-ticket-go/notify"
+	"bilibili-ticket-go/scheduler"
+	"bilibili-ticket-go/utils"
+	"context"
+	errors2 "errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var logger = utils.GetLogger(global.GetLogger(), "ticket", nil)
+
+// Entry 管理器中的一个任务
+type Entry struct {
+	ID       string
+	Ticket   models.TicketEntry
+	Routine  *Routine
+	LogCache *hooks.LoggerCache
+}
+
+// Event 任务状态变更事件
+type Event struct {
+	ID     string
+	From   enums.RoutineState
+	To     enums.RoutineState
+	Result models.TicketResult
+	Time   time.Time
+}
+
+// Manager 根据 DataStorage 维护所有抢票任务及其调度
+type Manager struct {
+	mutex      sync.RWMutex
+	client     *client.Client
+	storage    *models.DataStorage
+	scheduler  *scheduler.DynamicScheduler
+	notify     notify.Notify
+	entries    map[string]*Entry
+	order      []string
+	listeners  map[int]func(Event)
+	listenerID int
+	// 挂到每个任务 logger 上的额外 hook, 例如日志页的缓冲区
+	logHooks []logrus.Hook
+	// syncMutex 保证同一时间只有一个 Sync, 创建任务时不持有 mutex
+	syncMutex sync.Mutex
+	// retry 有任务创建失败时重新 Sync 的定时器
+	retry  *time.Timer
+	closed bool
+}
+
+// createRetryDelay 任务创建失败(通常是网络错误)后重试的间隔
+const createRetryDelay = 30 * time.Second
+
+func NewManager(client *client.Client, storage *models.DataStorage, scheduler *scheduler.DynamicScheduler, notify notify.Notify) *Manager {
+	return &Manager{
+		client:    client,
+		storage:   storage,
+		scheduler: scheduler,
+		notify:    notify,
+		entries:   make(map[string]*Entry),
+		listeners: make(map[int]func(Event)),
+	}
+}
+
+// AddLogHook 添加挂到任务 logger 上的 hook, 只对之后创建的任务生效
+func (m *Manager) AddLogHook(hook logrus.Hook) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.logHooks = append(m.logHooks, hook)
+}
+
+// Sync 根据存储内容创建新任务, 移除已删除的任务, 内容被修改的任务会重新创建
+// 创建任务需要请求登录状态, 在锁外进行; 创建失败的任务在 createRetryDelay 后重试
+func (m *Manager) Sync() {
+	m.syncMutex.Lock()
+	defer m.syncMutex.Unlock()
+	tickets := m.storage.GetTickets()
+	m.mutex.RLock()
+	if m.closed {
+		m.mutex.RUnlock()
+		return
+	}
+	hooksList := m.logHooks
+	caches := make(map[string]*hooks.LoggerCache, len(tickets))
+	var pending []models.TicketEntry
+	for _, t := range tickets {
+		old, ok := m.entries[t.ID]
+		if ok && old.Ticket.SameContent(t) {
+			continue
+		}
+		// 编辑过的任务沿用日志缓存
+		if ok {
+			caches[t.ID] = old.LogCache
+		} else {
+			caches[t.ID] = hooks.NewLoggerCache(200, nil)
+		}
+		pending = append(pending, t)
+	}
+	m.mutex.RUnlock()
+	built := make(map[string]*Entry, len(pending))
+	failed := 0
+	for _, t := range pending {
+		id := t.ID
+		cache := caches[id]
+		err, routine := NewTicketRoutine(m.client, t, append([]logrus.Hook{cache}, hooksList...), m.notify)
+		if err != nil {
+			logger.Errorf("Failed to create ticket routine[id:%s], retrying in %s: %v", id[:11], createRetryDelay, err)
+			failed++
+			continue
+		}
+		entry := &Entry{
+			ID:       id,
+			Ticket:   t,
+			Routine:  routine,
+			LogCache: cache,
+		}
+		routine.SetStateChangeFunc(func(from, to enums.RoutineState, result models.TicketResult) {
+			m.onStateChange(entry, from, to, result)
+		})
+		built[id] = entry
+	}
+	var created []*Entry
+	var removed []*Entry
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		for _, entry := range built {
+			entry.Routine.Close()
+		}
+		return
+	}
+	order := make([]string, 0, len(tickets))
+	exists := make(map[string]bool, len(tickets))
+	for _, t := range tickets {
+		id := t.ID
+		exists[id] = true
+		entry, ok := built[id]
+		if !ok {
+			old, ok := m.entries[id]
+			if !ok {
+				continue
+			}
+			if old.Ticket.SameContent(t) {
+				order = append(order, id)
+				continue
+			}
+			// 编辑后重新创建失败, 旧任务的内容已过期, 停止后等待重试
+			removed = append(removed, old)
+			delete(m.entries, id)
+			continue
+		}
+		if old, ok := m.entries[id]; ok {
+			// 旧的 routine 在锁外停止
+			removed = append(removed, old)
+		}
+		m.entries[id] = entry
+		order = append(order, id)
+		created = append(created, entry)
+	}
+	for id, entry := range m.entries {
+		if !exists[id] {
+			removed = append(removed, entry)
+			delete(m.entries, id)
+		}
+	}
+	m.order = order
+	if failed > 0 && m.retry == nil && !m.closed {
+		m.retry = time.AfterFunc(createRetryDelay, func() {
+			m.mutex.Lock()
+			m.retry = nil
+			m.mutex.Unlock()
+			m.Sync()
+		})
+	}
+	m.mutex.Unlock()
+	for _, entry := range removed {
+		m.unschedule(entry.ID)
+		entry.Routine.Stop()
+		entry.Routine.Close()
+	}
+	for _, entry := range created {
+		m.schedule(entry)
+	}
+}
+
+// schedule 将排队中的任务按开售时间加入调度器
+func (m *Manager) schedule(entry *Entry) {
+	if entry.Routine.State() != enums.StateQueued {
+		return
+	}
+	routine := entry.Routine
+	start := time.Unix(entry.Ticket.Start, 0)
+	m.scheduler.AddTask(entry.ID, start, func() {
+		routine.Start()
+	})
+	if check := start.Add(-buyerCheckAhead); check.After(time.Now()) {
+		m.scheduler.AddTask(buyerCheckTaskID(entry.ID), check, func() {
+			m.checkBuyersBeforeSale(entry)
+		})
+	}
+	routine.Schedule()
+}
+
+// unschedule 移除任务的开售和开售前检查
+func (m *Manager) unschedule(id string) {
+	m.scheduler.RemoveTask(id)
+	m.scheduler.RemoveTask(buyerCheckTaskID(id))
+}
+
+func buyerCheckTaskID(id string) string {
+	return id + "/buyers"
+}
+
+// onStateChange 已被移除或替换的任务的状态变更会被忽略
+func (m *Manager) onStateChange(entry *Entry, from, to enums.RoutineState, result models.TicketResult) {
+	m.mutex.RLock()
+	current := m.entries[entry.ID] == entry
+	m.mutex.RUnlock()
+	if !current {
+		return
+	}
+	id := entry.ID
+	if to.IsFinished() || to == enums.StatePaused {
+		m.unschedule(id)
+	}
+	if err := m.storage.UpdateTicketState(id, to, &result); err != nil {
+		logger.Errorf("Failed to save ticket state[id:%s]: %v", id[:11], err)
+	}
+	m.emit(Event{
+		ID:     id,
+		From:   from,
+		To:     to,
+		Result: result,
+		Time:   time.Now(),
+	})
+}
+
+// Entries 按存储顺序返回所有任务
+func (m *Manager) Entries() []*Entry {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	entries := make([]*Entry, 0, len(m.order))
+	for _, h := range m.order {
+		if entry, ok := m.entries[h]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Get 通过完整的 ID 或唯一的 ID 前缀查找任务
+func (m *Manager) Get(id string) (*Entry, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if entry, ok := m.entries[id]; ok {
+		return entry, true
+	}
+	if id == "" {
+		return nil, false
+	}
+	var found *Entry
+	for h, entry := range m.entries {
+		if strings.HasPrefix(h, id) {
+			if found != nil {
+				return nil, false
+			}
+			found = entry
+		}
+	}
+	return found, found != nil
+}
+
+// Start 立即启动任务, 正在运行的任务会被重新启动
+func (m *Manager) Start(id string) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	routine := entry.Routine
+	if routine.State() == enums.StateSucceeded {
+		return errors.NewRoutineStateError(entry.ID, routine.State().String(), "start")
+	}
+	if routine.IsRunning() {
+		routine.Stop()
+	}
+	m.unschedule(entry.ID)
+	if !routine.Start() {
+		return errors.NewRoutineStateError(entry.ID, routine.State().String(), "start")
+	}
+	return nil
+}
+
+// Stop 取消任务但保留在队列中
+func (m *Manager) Stop(id string) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	m.unschedule(entry.ID)
+	if !entry.Routine.Stop() {
+		return errors.NewRoutineStateError(entry.ID, entry.Routine.State().String(), "stop")
+	}
+	return nil
+}
+
+// Pause 暂停任务, 暂停期间到了开售时间也不会启动
+func (m *Manager) Pause(id string) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	if !entry.Routine.Pause() {
+		return errors.NewRoutineStateError(entry.ID, entry.Routine.State().String(), "pause")
+	}
+	return nil
+}
+
+// Resume 恢复暂停的任务并重新加入调度, 已过开售时间的任务会立即启动
+func (m *Manager) Resume(id string) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	if !entry.Routine.Resume() {
+		return errors.NewRoutineStateError(entry.ID, entry.Routine.State().String(), "resume")
+	}
+	m.schedule(entry)
+	return nil
+}
+
+// Update 修改任务内容, ID 和日志保持不变, 运行中或已成功的任务不能修改
+func (m *Manager) Update(id string, ticket models.TicketEntry) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	if state := entry.Routine.State(); state == enums.StateRunning || state == enums.StateSucceeded {
+		return errors.NewRoutineStateError(entry.ID, state.String(), "edit")
+	}
+	if !ticket.Valid() {
+		return errors.NewRoutineCreateError("ticket data is invalid")
+	}
+	return storageError(id, m.storage.UpdateTicket(entry.ID, ticket))
+}
+
+// Move 调整任务在列表中的顺序, offset 为负数时向前移动
+func (m *Manager) Move(id string, offset int) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	return storageError(id, m.storage.MoveTicket(entry.ID, offset))
+}
+
+// Remove 取消任务并从存储中删除
+func (m *Manager) Remove(id string) error {
+	entry, ok := m.Get(id)
+	if !ok {
+		return errors.NewRoutineNotFoundError(id)
+	}
+	m.unschedule(entry.ID)
+	entry.Routine.Stop()
+	entry.Routine.Close()
+	return storageError(id, m.storage.RemoveTicketByID(entry.ID))
+}
+
+// storageError 把存储返回的错误转换为任务管理器的错误, 写入文件失败等其他错误原样返回
+func storageError(id string, err error) error {
+	var notFound *errors.EntryNotFoundError
+	var exists *errors.EntryExistsError
+	switch {
+	case errors2.As(err, &notFound):
+		return errors.NewRoutineNotFoundError(id)
+	case errors2.As(err, &exists):
+		return errors.NewRoutineCreateError("the same ticket is already in the queue")
+	}
+	return err
+}
+
+// Shutdown 程序退出时移除所有调度, 通过 context 取消运行中的任务并等待, 最后关闭日志文件
+// ctx 结束时仍未停止的任务会被放弃, 返回 ctx 的错误
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mutex.Lock()
+	m.closed = true
+	if m.retry != nil {
+		m.retry.Stop()
+		m.retry = nil
+	}
+	m.mutex.Unlock()
+	entries := m.Entries()
+	for _, entry := range entries {
+		m.unschedule(entry.ID)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(entries))
+	for i, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := entry.Routine.Shutdown(ctx); err != nil {
+				logger.Warnf("Ticket routine did not stop in time[id:%s]: %v", entry.ID[:11], err)
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+	for _, entry := range entries {
+		if err := entry.Routine.Close(); err != nil {
+			logger.Warnf("Failed to close ticket log[id:%s]: %v", entry.ID[:11], err)
+		}
+	}
+	return errors2.Join(errs...)
+}
+
+// Subscribe 订阅任务状态变更事件, 回调中不能阻塞, 返回取消订阅的函数
+func (m *Manager) Subscribe(f func(Event)) func() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.listenerID++
+	id := m.listenerID
+	m.listeners[id] = f
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		delete(m.listeners, id)
+	}
+}
+
+func (m *Manager) emit(event Event) {
+	m.mutex.RLock()
+	listeners := make([]func(Event), 0, len(m.listeners))
+	for _, f := range m.listeners {
+		listeners = append(listeners, f)
+	}
+	m.mutex.RUnlock()
+	for _, f := range listeners {
+		f(event)
+	}
+}
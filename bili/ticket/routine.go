@@ -16,7 +16,9 @@ import (
 	errors2 "errors"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,14 +27,23 @@ import (
 )
 
 type Routine struct {
-	mutex     sync.RWMutex
-	client    *client.Client
-	buyer     r.TicketBuyer
-	ticket    models.TicketEntry
-	ctx       context.Context
-	isRunning bool
-	cancel    context.CancelFunc
-	logger    *logrus.Entry
+	mutex         sync.RWMutex
+	callbackMutex sync.Mutex
+	client        *client.Client
+	ticket        models.TicketEntry
+	state         enums.RoutineState
+	result        models.TicketResult
+	stats         *Stats
+	runID         uint64
+	cancel        context.CancelFunc
+	running       sync.WaitGroup
+	// closed 程序退出时设置, 之后不再启动, 被取消的运行结果也不再记录
+	closed        bool
+	logger        *logrus.Entry
+	fileLogger    *timberjack.Logger
+	notify        notify.Notify
+	account       *api.GetLoginInfoStruct
+	onStateChange func(from, to enums.RoutineState, result models.TicketResult)
 }
 
 func NewTicketRoutine(client *client.Client, ticket models.TicketEntry, h []logrus.Hook, notify notify.Notify) (error, *Routine) {
@@ -42,12 +53,14 @@ func NewTicketRoutine(client *client.Client, ticket models.TicketEntry, h []logr
 	if client == nil {
 		return errors.NewRoutineCreateError("bili-client is nil"), nil
 	}
+	if len(ticket.ID) < 11 {
+		return errors.NewRoutineCreateError("ticket id is missing"), nil
+	}
 	err, info := client.GetLoginStatus()
 	if err != nil {
 		return errors2.Join(errors.NewRoutineCreateError("get login status error"), err), nil
 	}
-	hash := ticket.Hash()
-	ctx, cancel := context.WithCancel(context.Background())
+	id := ticket.ID
 	logger := logrus.New()
 	logger.SetOutput(io.Discard)
 	level, err := strconv.Atoi(global.LoggerLevel)
@@ -56,7 +69,7 @@ func NewTicketRoutine(client *client.Client, ticket models.TicketEntry, h []logr
 	}
 	logger.SetLevel(logrus.Level(level))
 	fileLogger := &timberjack.Logger{
-		Filename:    "logs/tickets/" + hash[0:11] + ".log",
+		Filename:    global.StatePath("logs", "tickets", id[0:11]+".log"),
 		MaxSize:     1e5, // megabytes
 		MaxBackups:  0,   // backups
 		MaxAge:      7,   // days
@@ -67,67 +80,222 @@ func NewTicketRoutine(client *client.Client, ticket models.TicketEntry, h []logr
 	for _, hook := range h {
 		logger.AddHook(hook)
 	}
-	entry := utils.GetLogger(logger, fmt.Sprintf("%s", hash[0:11]), nil)
+	entry := utils.GetLogger(logger, fmt.Sprintf("%s", id[0:11]), nil)
+	state := ticket.State
+	if state == enums.StateRunning || state == enums.StateScheduled {
+		// 上次退出时任务还没结束, 重新排队
+		state = enums.StateQueued
+	}
 	tr := &Routine{
-		client:    client,
-		isRunning: false,
-		ticket:    ticket,
-		ctx:       ctx,
-		cancel:    cancel,
-		logger:    entry,
-	}
-	logger.AddHook(hooks.NewRoutineHandlerHook(func(i int, fields logrus.Fields) {
-		if i == enums.Success || i == enums.Failed || i == enums.Error {
-			entry.Info("Ticket Routine stopped")
-			tr.setIsRunning(false)
-		}
-		if i == enums.Success && notify != nil {
-			notify.Notify(fmt.Sprintf("抢票成功！\n项目：%s\n场次：%s\n票种：%s\n购票人：%s\n购票用户：%s(%d)", ticket.ProjectName, ticket.ScreenName, ticket.SkuName, ticket.Buyer.String(), info.Name, info.UID))
-		}
-	}))
+		client:     client,
+		ticket:     ticket,
+		state:      state,
+		result:     ticket.LastResult,
+		stats:      NewStats(),
+		logger:     entry,
+		fileLogger: fileLogger,
+		notify:     notify,
+		account:    info,
+	}
 	utils.RegisterLoggerFormater(logger)
-	entry.Info("Ticket Routine created")
+	entry.Infof("Ticket Routine created, state: %s", state)
 	return nil, tr
 }
 
-func (tr *Routine) Start() {
+// SetStateChangeFunc 设置状态变更回调, 回调在状态锁之外执行
+func (tr *Routine) SetStateChangeFunc(f func(from, to enums.RoutineState, result models.TicketResult)) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.onStateChange = f
+}
+
+// State 获取当前状态
+func (tr *Routine) State() enums.RoutineState {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.state
+}
+
+// Result 获取最近一次结束时的结果
+func (tr *Routine) Result() models.TicketResult {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.result
+}
+
+// Stats 获取当前运行的统计摘要
+func (tr *Routine) Stats() models.TicketStats {
+	return tr.stats.Snapshot()
+}
+
+func (tr *Routine) IsRunning() bool {
+	return tr.State() == enums.StateRunning
+}
+
+// Schedule Queued -> Scheduled
+func (tr *Routine) Schedule() bool {
+	tr.mutex.Lock()
+	if tr.state != enums.StateQueued {
+		tr.mutex.Unlock()
+		return false
+	}
+	tr.commit(enums.StateScheduled)
+	return true
+}
+
+// Start 启动抢票, 已经成功或正在运行的任务不会再次启动
+func (tr *Routine) Start() bool {
+	tr.mutex.Lock()
+	if tr.closed {
+		tr.mutex.Unlock()
+		return false
+	}
+	if !tr.state.CanTransitionTo(enums.StateRunning) {
+		state := tr.state
+		tr.mutex.Unlock()
+		tr.logger.Warnf("Ticket Routine can not start from state %s", state)
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tr.cancel = cancel
+	tr.runID++
+	id := tr.runID
+	tr.stats.Reset()
+	tr.commit(enums.StateRunning)
 	tr.logger.Info("Ticket Routine started")
-	if tr.IsRunning() {
-		return
+	tr.running.Add(1)
+	go func() {
+		defer tr.running.Done()
+		state, result := run(tr.client, tr.ticket, 500*time.Millisecond, ctx, tr.logger, tr.stats)
+		tr.finish(id, state, result)
+	}()
+	return true
+}
+
+// Stop 取消任务 -> Cancelled
+func (tr *Routine) Stop() bool {
+	return tr.interrupt(enums.StateCancelled)
+}
+
+// Pause 暂停任务 -> Paused, 运行中的请求会被取消
+func (tr *Routine) Pause() bool {
+	return tr.interrupt(enums.StatePaused)
+}
+
+// Resume Paused -> Queued, 等待重新调度
+func (tr *Routine) Resume() bool {
+	tr.mutex.Lock()
+	if tr.state != enums.StatePaused {
+		tr.mutex.Unlock()
+		return false
 	}
+	tr.commit(enums.StateQueued)
+	return true
+}
 
-	tr.setIsRunning(true)
-	go run(tr.client, tr.ticket, 500*time.Millisecond, tr.ctx, tr.logger)
+func (tr *Routine) interrupt(target enums.RoutineState) bool {
+	tr.mutex.Lock()
+	if !tr.state.CanTransitionTo(target) {
+		tr.mutex.Unlock()
+		return false
+	}
+	if tr.cancel != nil {
+		tr.cancel()
+		tr.cancel = nil
+	}
+	if target.IsFinished() {
+		tr.result = models.TicketResult{
+			State:   target,
+			Message: strings.ToLower(target.String()),
+			Time:    time.Now().Unix(),
+			Stats:   tr.stats.Snapshot(),
+		}
+	}
+	tr.commit(target)
+	tr.logger.Infof("Ticket Routine %s", strings.ToLower(target.String()))
+	return true
 }
 
-func (tr *Routine) setIsRunning(val bool) {
-	//tr.mutex.Lock()
-	//defer tr.mutex.Unlock()
-	tr.isRunning = val
+// Shutdown 程序退出时取消正在运行的请求并等待 run 返回, 直到 ctx 结束
+// 状态保持不变, 下次启动时运行中和已调度的任务会重新排队
+func (tr *Routine) Shutdown(ctx context.Context) error {
+	tr.mutex.Lock()
+	tr.closed = true
+	if tr.cancel != nil {
+		tr.cancel()
+		tr.cancel = nil
+	}
+	tr.mutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		tr.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (tr *Routine) IsRunning() bool {
-	//tr.mutex.RLock()
-	//defer tr.mutex.RUnlock()
-	return tr.isRunning
+// Close 关闭任务的日志文件
+func (tr *Routine) Close() error {
+	return tr.fileLogger.Close()
 }
 
-func (tr *Routine) Stop() {
-	tr.logger.Info("Ticket Routine stopped")
-	if !tr.IsRunning() {
+// finish 处理 run 的返回值, 已经被取消或重新启动的旧 run 会被忽略
+// 退出时被取消的 run 也会被忽略, 但退出前刚好结束的结果仍会记录
+func (tr *Routine) finish(id uint64, state enums.RoutineState, result models.TicketResult) {
+	tr.mutex.Lock()
+	if tr.closed && state == enums.StateCancelled {
+		tr.mutex.Unlock()
 		return
 	}
+	if tr.runID != id || tr.state != enums.StateRunning || !tr.state.CanTransitionTo(state) {
+		tr.mutex.Unlock()
+		return
+	}
+	if tr.cancel != nil {
+		tr.cancel()
+		tr.cancel = nil
+	}
+	result.State = state
+	result.Time = time.Now().Unix()
+	result.Stats = tr.stats.Snapshot()
+	tr.result = result
+	tr.commit(state)
+	tr.logger.Infof("Ticket Routine stopped, state: %s, attempts: %d, token refreshes: %d", state, result.Stats.Attempts, result.Stats.TokenRefreshes)
+	if state == enums.StateSucceeded && tr.notify != nil {
+		choice := tr.ticket.Choices()[0]
+		if i := tr.ticket.ChoiceIndex(result.ScreenID, result.SkuID); i > 0 {
+			choice = tr.ticket.Choices()[i]
+		}
+		tr.notify.Notify(fmt.Sprintf("抢票成功！\n项目：%s\n场次：%s\n票种：%s\n购票人：%s\n购票用户：%s(%d)", tr.ticket.ProjectName, choice.ScreenName, choice.SkuName, tr.ticket.BuyerNames(), tr.account.Name, tr.account.UID))
+	}
+}
 
-	tr.cancel()
-	tr.setIsRunning(false)
+// commit 切换状态并释放写锁, 调用前必须持有写锁
+// 回调锁在写锁释放前获取, 保证回调顺序与状态转换顺序一致, 回调中不能再修改本任务的状态
+func (tr *Routine) commit(state enums.RoutineState) {
+	from := tr.state
+	tr.state = state
+	f := tr.onStateChange
+	result := tr.result
+	tr.callbackMutex.Lock()
+	tr.mutex.Unlock()
+	defer tr.callbackMutex.Unlock()
+	if f != nil {
+		f(from, state, result)
+	}
 }
 
-func run(client *client.Client, ticketData models.TicketEntry, interval time.Duration, ctx context.Context, logger *logrus.Entry) {
+func run(client *client.Client, ticketData models.TicketEntry, interval time.Duration, ctx context.Context, logger *logrus.Entry, stats *Stats) (enums.RoutineState, models.TicketResult) {
 	pidString := strconv.FormatInt(ticketData.ProjectID, 10)
 	err, info := client.GetProjectInformation(pidString)
 	if err != nil {
 		logger.WithField("status", enums.Error).WithError(err).Error("GetProjectInformation err")
-		return
+		return enums.StateErrored, errorResult(err)
 	}
 	var tokenGen token.Generator
 	if info.IsHotProject {
@@ -135,76 +303,157 @@ func run(client *client.Client, ticketData models.TicketEntry, interval time.Dur
 	} else {
 		tokenGen = token.NewNormalTokenGenerator()
 	}
-	err, tickets := client.GetTicketSkuIDsByProjectID(pidString)
-	if err != nil {
-		logger.WithField("status", enums.Error).WithError(err).Errorf("GetTicketSkuIDsByProjectID err: %v", err)
-		return
-	}
-	var ticket *r.TicketSkuScreenID
-	for _, t := range tickets {
-		if t.SkuID == ticketData.SkuID && t.ScreenID == ticketData.ScreenID {
-			ticket = &t
+	tickets := info.Tickets()
+	var addresses []api.AddressStruct
+	choices := ticketData.Choices()
+	var (
+		state  enums.RoutineState
+		result models.TicketResult
+		next   bool
+	)
+	// 按优先级依次尝试, 票种不存在, 不可售, 超出最高价格或收货地址不可用时换下一个
+	for i, choice := range choices {
+		if i > 0 {
+			logger.Warnf("Trying alternative %d/%d: %s", i, len(choices)-1, choice)
+		}
+		var ticket *r.TicketSkuScreenID
+		for _, t := range tickets {
+			if t.SkuID == choice.SkuID && t.ScreenID == choice.ScreenID {
+				ticket = &t
+				break
+			}
+		}
+		if ticket == nil {
+			logger.WithField("status", enums.Error).Errorf("Ticket with skuID %d not found in project %d", choice.SkuID, ticketData.ProjectID)
+			state, result, next = enums.StateErrored, models.TicketResult{Message: fmt.Sprintf("ticket with skuID %d not found", choice.SkuID)}, true
+		} else if address, addressState, addressResult, ok := chooseAddress(client, ticketData, ticket, &addresses, logger); !ok {
+			// 收货地址的问题只影响这个选择, 和不可售一样换下一个
+			state, result, next = addressState, addressResult, true
+		} else {
+			state, result, next = runChoice(client, ticketData, ticket, address, tokenGen, interval, ctx, logger, stats)
+		}
+		result.ScreenID = choice.ScreenID
+		result.SkuID = choice.SkuID
+		if !next {
 			break
 		}
 	}
-	if ticket == nil {
-		logger.WithField("status", enums.Error).WithError(err).Errorf("Ticket with skuID %d not found in project %d", ticketData.SkuID, ticketData.ProjectID)
-		return
+	return state, result
+}
+
+// chooseAddress 返回票种使用的收货地址, 不需要快递时为 nil
+// 失败时返回 false 和这个选择的状态与结果; 地址列表只在第一次需要时获取, 获取失败时下一个选择会重试
+func chooseAddress(client *client.Client, ticketData models.TicketEntry, ticket *r.TicketSkuScreenID, addresses *[]api.AddressStruct, logger *logrus.Entry) (*api.AddressStruct, enums.RoutineState, models.TicketResult, bool) {
+	if !ticket.NeedDelivery && ticketData.AddressID == 0 {
+		return nil, 0, models.TicketResult{}, true
+	}
+	if ticketData.AddressID == 0 {
+		logger.WithField("status", enums.Failed).Warn("This ticket ships by express, but no delivery address was selected")
+		return nil, enums.StateFailed, models.TicketResult{Message: "delivery address is required"}, false
 	}
+	if *addresses == nil {
+		err, list := client.GetAddressList()
+		if err != nil {
+			logger.WithField("status", enums.Error).WithError(err).Errorf("GetAddressList err: %v", err)
+			return nil, enums.StateErrored, errorResult(err), false
+		}
+		*addresses = list
+	}
+	for i, a := range *addresses {
+		if a.Id == ticketData.AddressID {
+			return &(*addresses)[i], 0, models.TicketResult{}, true
+		}
+	}
+	logger.WithField("status", enums.Error).Errorf("Delivery address %d not found", ticketData.AddressID)
+	return nil, enums.StateErrored, models.TicketResult{Message: fmt.Sprintf("address %d not found", ticketData.AddressID)}, false
+}
+
+// runChoice 对一个场次和票种循环下单, 返回的 bool 为 true 时表示可以尝试下一个备选
+func runChoice(client *client.Client, ticketData models.TicketEntry, ticket *r.TicketSkuScreenID, address *api.AddressStruct, tokenGen token.Generator, interval time.Duration, ctx context.Context, logger *logrus.Entry, stats *Stats) (enums.RoutineState, models.TicketResult, bool) {
+	pidString := strconv.FormatInt(ticketData.ProjectID, 10)
+	maxPrice := ticketData.MaxPrice
+	if maxPrice <= 0 {
+		maxPrice = ticket.Price
+	}
+	if ticket.Price > maxPrice {
+		err := errors.NewTicketPriceExceededError(ticket.Price, maxPrice)
+		logger.WithField("status", enums.Failed).Warn(err.Error())
+		return enums.StateFailed, models.TicketResult{Message: err.Error()}, true
+	}
+	count := ticketData.Count()
 	whenGenPtoken := time.Now()
-	err, tk := client.GetRequestTokenAndPToken(tokenGen, strconv.FormatInt(ticketData.ProjectID, 10), *ticket)
+	err, tk := client.GetRequestTokenAndPToken(tokenGen, pidString, *ticket, count)
+	stats.Observe(StagePrepare, whenGenPtoken)
 	if err != nil {
 		logger.WithField("status", enums.Error).WithError(err).Errorf("GetRequestTokenAndPToken err: %v", err)
-		return
+		return enums.StateErrored, errorResult(err), false
 	}
-	var count uint16 = 0
+	var tries uint16 = 0
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return enums.StateCancelled, models.TicketResult{Message: "cancelled"}, false
 		default:
 			var (
 				err            error
 				code           int
 				msg            string
 				to             api.TicketOrderStruct
+				confirm        *api.ConfirmStruct
 				buyerInterface interface{}
+				began          time.Time
 			)
-			if count >= 61 {
+			if tries >= 61 {
 				// 该换个新token去骗叔叔了
 				whenGenPtoken = time.Now()
-				err, tk = client.GetRequestTokenAndPToken(tokenGen, strconv.FormatInt(ticketData.ProjectID, 10), *ticket)
+				err, tk = client.GetRequestTokenAndPToken(tokenGen, pidString, *ticket, count)
+				stats.Observe(StagePrepare, whenGenPtoken)
+				stats.AddTokenRefresh()
 				if err != nil {
 					logger.WithField("status", enums.Error).WithError(err).Errorf("GetRequestTokenAndPToken err: %v", err)
+				} else {
+					tries = 0
 				}
 				goto SLEEP
 			}
+			began = time.Now()
+			err, confirm = client.GetConfirmInformation(tk, pidString)
+			stats.Observe(StageConfirm, began)
+			if err != nil {
+				logger.WithField("status", enums.Error).WithError(err).Errorf("GetConfirmInformation err: %v", err)
+				goto SLEEP
+			}
+			// 订单信息与任务不一致时直接失败, 不自动适应服务器返回的价格或票种
+			if err = checkConfirm(confirm, ticket, maxPrice, count); err != nil {
+				logger.WithField("status", enums.Failed).Warnf("Order confirmation does not match the ticket: %v", err)
+				var priceErr *errors.TicketPriceExceededError
+				return enums.StateFailed, models.TicketResult{Message: err.Error()}, errors2.As(err, &priceErr)
+			}
 			if ticketData.Buyer.BuyerType == enums.Ordinary {
 				buyerInterface = map[string]string{
 					"tel":  ticketData.Buyer.Tel,
 					"name": ticketData.Buyer.Name,
 				}
 			} else if ticketData.Buyer.BuyerType == enums.ForceRealName {
-				err, confirm := client.GetConfirmInformation(tk, strconv.FormatInt(ticketData.ProjectID, 10))
-				if err != nil {
-					logger.WithField("status", enums.Error).WithError(err).Errorf("GetConfirmInformation err: %v", err)
-					goto SLEEP
-				}
-				for _, b := range confirm.BuyerList.List {
-					if b.Id == ticketData.Buyer.ID {
-						buyerInterface = b
+				buyers := make([]api.BuyerStruct, 0, count)
+				for _, want := range ticketData.AllBuyers() {
+					index := slices.IndexFunc(confirm.BuyerList.List, func(b api.BuyerStruct) bool { return b.Id == want.ID })
+					if index < 0 {
+						logger.WithField("status", enums.Error).Errorf("GetConfirmInformation buyer %d not found in project %d", want.ID, ticketData.ProjectID)
+						return enums.StateErrored, models.TicketResult{Message: fmt.Sprintf("buyer %d not found", want.ID)}, false
 					}
+					buyers = append(buyers, confirm.BuyerList.List[index])
 				}
-				if buyerInterface == nil {
-					logger.WithField("status", enums.Error).WithError(err).Errorf("GetConfirmInformation buyer not found in project %d", ticketData.ProjectID)
-					return
-				}
+				buyerInterface = buyers
 			}
-			err, code, msg, to = client.SubmitOrder(tokenGen, whenGenPtoken, tk, pidString, *ticket, buyerInterface, ticketData.Buyer.BuyerType)
+			began = time.Now()
+			err, code, msg, to = client.SubmitOrder(tokenGen, whenGenPtoken, tk, pidString, *ticket, count, buyerInterface, ticketData.Buyer.BuyerType, address)
+			stats.Observe(StageCreate, began)
 			if err != nil {
 				logger.WithField("status", enums.Error).WithError(err).Errorf("SubmitOrder err: %v", err)
 				goto SLEEP
 			}
+			stats.AddAttempt(code)
 			if (code == 0 || code == 100048 || code == 100079) && to.OrderId != 0 {
 				// 肘击成功
 				logger.WithFields(logrus.Fields{
@@ -215,12 +464,20 @@ func run(client *client.Client, ticketData models.TicketEntry, interval time.Dur
 						"order":   to.OrderId,
 					},
 				}).Infof("SubmitOrder success, orderID: %d", to.OrderId)
-				return
+				return enums.StateSucceeded, models.TicketResult{Code: code, Message: msg, OrderID: to.OrderId}, false
 			} else if code == 100034 {
-				// 价格不对捏
-				ticket.Price = to.PayMoney
+				// 价格不对捏, 不自动改价
+				err = errors.NewTicketMismatchError("price", strconv.Itoa(ticket.PayMoney(count)), strconv.Itoa(to.PayMoney))
+				logger.WithFields(logrus.Fields{
+					"status": enums.Failed,
+					"bili": logrus.Fields{
+						"code":    code,
+						"message": msg,
+					},
+				}).Warnf("%s (%d): %v", msg, code, err)
+				return enums.StateFailed, models.TicketResult{Code: code, Message: err.Error()}, false
 			} else if code == 100017 {
-				// 不可售
+				// 不可售, 换下一个备选
 				logger.WithFields(logrus.Fields{
 					"status": enums.Failed,
 					"bili": logrus.Fields{
@@ -228,7 +485,7 @@ func run(client *client.Client, ticketData models.TicketEntry, interval time.Dur
 						"message": msg,
 					},
 				}).Warnf("%s (%d)", msg, code)
-				return
+				return enums.StateFailed, models.TicketResult{Code: code, Message: msg}, true
 			}
 			logger.WithFields(logrus.Fields{
 				"status": enums.Pending,
@@ -238,8 +495,40 @@ func run(client *client.Client, ticketData models.TicketEntry, interval time.Dur
 				},
 			}).Infof("%s (%d)", msg, code)
 		SLEEP:
-			count++
+			tries++
 			time.Sleep(interval)
 		}
 	}
 }
+
+// checkConfirm 检查确认页返回的票种, 场次和价格是否与任务一致, 纸质票的应付金额包含快递费
+func checkConfirm(confirm *api.ConfirmStruct, ticket *r.TicketSkuScreenID, maxPrice int, count int) error {
+	if int64(confirm.TicketInfo.SkuId) != ticket.SkuID {
+		return errors.NewTicketMismatchError("sku_id", strconv.FormatInt(ticket.SkuID, 10), strconv.Itoa(confirm.TicketInfo.SkuId))
+	}
+	if int64(confirm.ScreenId) != ticket.ScreenID {
+		return errors.NewTicketMismatchError("screen_id", strconv.FormatInt(ticket.ScreenID, 10), strconv.Itoa(confirm.ScreenId))
+	}
+	if confirm.BuyerList.MaxLimit > 0 && count > confirm.BuyerList.MaxLimit {
+		return errors.NewTicketMismatchError("count", fmt.Sprintf("at most %d", confirm.BuyerList.MaxLimit), strconv.Itoa(count))
+	}
+	if confirm.Count != 0 && confirm.Count != count {
+		return errors.NewTicketMismatchError("count", strconv.Itoa(count), strconv.Itoa(confirm.Count))
+	}
+	// 最高价格只限制票价, 快递费不计入
+	if confirm.PayMoney-ticket.ExpressFee > maxPrice*count {
+		return errors.NewTicketPriceExceededError(confirm.PayMoney-ticket.ExpressFee, maxPrice*count)
+	}
+	if confirm.PayMoney != ticket.PayMoney(count) {
+		return errors.NewTicketMismatchError("pay_money", strconv.Itoa(ticket.PayMoney(count)), strconv.Itoa(confirm.PayMoney))
+	}
+	return nil
+}
+
+func errorResult(err error) models.TicketResult {
+	var apiErr *errors.BilibiliAPIError
+	if errors2.As(err, &apiErr) {
+		return models.TicketResult{Code: apiErr.Code, Message: apiErr.Message}
+	}
+	return models.TicketResult{Code: -1, Message: err.Error()}
+}
@@ -0,0 +1,60 @@
+package ticket
+
+import (
+	"bilibili-ticket-go/models/bili/api"
+	r "bilibili-ticket-go/models/bili/return"
+	"bilibili-ticket-go/models/errors"
+	stderrors "errors"
+	"testing"
+)
+
+func newTestConfirm(screenID int64, skuID int64, count int, payMoney int) *api.ConfirmStruct {
+	confirm := &api.ConfirmStruct{
+		Count:    count,
+		ScreenId: int(screenID),
+		PayMoney: payMoney,
+	}
+	confirm.TicketInfo.SkuId = int(skuID)
+	return confirm
+}
+
+func TestCheckConfirmDelivery(t *testing.T) {
+	ticket := &r.TicketSkuScreenID{ScreenID: 1, SkuID: 2, Price: 38000, NeedDelivery: true, ExpressFee: 1200}
+	tests := []struct {
+		name     string
+		payMoney int
+		maxPrice int
+		wantErr  any
+	}{
+		{"price and express fee", 2*38000 + 1200, 40000, nil},
+		{"express fee missing", 2 * 38000, 38000, &errors.TicketMismatchError{}},
+		{"express fee not counted in max price", 2*38000 + 1200, 38000, nil},
+		{"price above max price", 2*40000 + 1200, 38000, &errors.TicketPriceExceededError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConfirm(newTestConfirm(1, 2, 2, tt.payMoney), ticket, tt.maxPrice, 2)
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("checkConfirm() error = %v, want nil", err)
+				}
+			case *errors.TicketMismatchError:
+				if !stderrors.As(err, &want) {
+					t.Errorf("checkConfirm() error = %v, want TicketMismatchError", err)
+				}
+			case *errors.TicketPriceExceededError:
+				if !stderrors.As(err, &want) {
+					t.Errorf("checkConfirm() error = %v, want TicketPriceExceededError", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckConfirmWithoutDelivery(t *testing.T) {
+	ticket := &r.TicketSkuScreenID{ScreenID: 1, SkuID: 2, Price: 38000}
+	if err := checkConfirm(newTestConfirm(1, 2, 1, 38000), ticket, 38000, 1); err != nil {
+		t.Errorf("checkConfirm() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,153 @@
+package ticket
+
+import (
+	client "bilibili-ticket-go/bili"
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/metrics"
+	"bilibili-ticket-go/notify"
+	"bilibili-ticket-go/utils"
+	"context"
+	"sync"
+	"time"
+)
+
+var sessionLogger = utils.GetLogger(global.GetLogger(), "session", nil)
+
+const (
+	DefaultSessionCheckInterval = 30 * time.Minute
+	// MinSessionCheckInterval 检查间隔的下限, 刷新接口本身有频率限制
+	MinSessionCheckInterval = 5 * time.Minute
+)
+
+// SessionMonitor 定期检查登录状态, 按需刷新 Cookie 和 bili_ticket, 刷新后立即调用 persist 保存
+type SessionMonitor struct {
+	client   *client.Client
+	notify   notify.Notify
+	interval time.Duration
+	persist  func()
+
+	mutex     sync.Mutex
+	cancel    context.CancelFunc
+	trigger   chan struct{}
+	checked   bool
+	loggedIn  bool
+	lastCheck time.Time
+	// 同一次退出登录只提醒一次, 重新登录后复位
+	alerted bool
+}
+
+func NewSessionMonitor(client *client.Client, notify notify.Notify, interval time.Duration, persist func()) *SessionMonitor {
+	if interval <= 0 {
+		interval = DefaultSessionCheckInterval
+	}
+	if interval < MinSessionCheckInterval {
+		interval = MinSessionCheckInterval
+	}
+	return &SessionMonitor{
+		client:   client,
+		notify:   notify,
+		interval: interval,
+		persist:  persist,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+func (s *SessionMonitor) Interval() time.Duration {
+	return s.interval
+}
+
+// Start 在后台开始定期检查, 启动时立即检查一次
+func (s *SessionMonitor) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.loop(ctx)
+	sessionLogger.Infof("Session monitor started, interval: %s", s.interval)
+}
+
+func (s *SessionMonitor) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// CheckNow 让后台立即检查一次, 例如扫码登录之后
+func (s *SessionMonitor) CheckNow() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Status 返回最近一次成功检查的结果, checked 为 false 时还没有检查过
+func (s *SessionMonitor) Status() (checked bool, loggedIn bool, lastCheck time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.checked, s.loggedIn, s.lastCheck
+}
+
+func (s *SessionMonitor) loop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	s.check()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+		s.check()
+	}
+}
+
+func (s *SessionMonitor) check() {
+	err, stat := s.client.GetLoginStatus()
+	if err != nil {
+		sessionLogger.Warnf("Failed to check login status: %v", err)
+		return
+	}
+	s.mutex.Lock()
+	s.checked = true
+	s.loggedIn = stat.Login
+	s.lastCheck = time.Now()
+	alert := !stat.Login && !s.alerted
+	s.alerted = !stat.Login
+	s.mutex.Unlock()
+	if !stat.Login {
+		if alert {
+			sessionLogger.Warn("The account is logged out, ticket routines will not be able to place orders.")
+			if s.notify != nil {
+				s.notify.NotifyWithPriority("账号已退出登录，请尽快重新扫码登录，否则抢票任务无法下单。", notify.PriorityHigh)
+			}
+		}
+		return
+	}
+	refreshed := false
+	err, f := s.client.CheckAndUpdateCookie()
+	metrics.AddCookieRefresh("cookie", err, f)
+	if err != nil {
+		sessionLogger.Errorf("Failed to refresh cookie: %v", err)
+	} else if f {
+		sessionLogger.Info("Cookie refreshed successfully.")
+		refreshed = true
+	}
+	err, f = s.client.TryToRefreshNewBiliTicket()
+	metrics.AddCookieRefresh("bili_ticket", err, f)
+	if err != nil {
+		sessionLogger.Errorf("Failed to refresh bili-ticket: %v", err)
+	} else if f {
+		sessionLogger.Info("Bili-ticket refreshed successfully.")
+		refreshed = true
+	}
+	if refreshed && s.persist != nil {
+		s.persist()
+	}
+}
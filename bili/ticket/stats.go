@@ -0,0 +1,110 @@
+package ticket
+
+import (
+	"bilibili-ticket-go/metrics"
+	"bilibili-ticket-go/models"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StagePrepare = "prepare"
+	StageConfirm = "confirm"
+	StageCreate  = "create"
+)
+
+// 每个阶段最多保留的耗时样本数
+const maxLatencySamples = 1000
+
+// Stats 单个任务的请求统计
+type Stats struct {
+	mutex          sync.RWMutex
+	attempts       int
+	tokenRefreshes int
+	codes          map[int]int
+	latency        map[string][]time.Duration
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		codes:   make(map[int]int),
+		latency: make(map[string][]time.Duration),
+	}
+}
+
+// Reset 清空所有计数, 每次启动任务时调用
+func (s *Stats) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attempts = 0
+	s.tokenRefreshes = 0
+	s.codes = make(map[int]int)
+	s.latency = make(map[string][]time.Duration)
+}
+
+// AddAttempt 记录一次下单请求及其返回码
+func (s *Stats) AddAttempt(code int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attempts++
+	s.codes[code]++
+	metrics.AddAttempt(code)
+}
+
+func (s *Stats) AddTokenRefresh() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokenRefreshes++
+}
+
+// Observe 记录某个阶段从 since 到现在的耗时
+func (s *Stats) Observe(stage string, since time.Time) {
+	d := time.Since(since)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	samples := s.latency[stage]
+	if len(samples) >= maxLatencySamples {
+		samples = samples[1:]
+	}
+	s.latency[stage] = append(samples, d)
+}
+
+// Snapshot 生成当前统计的摘要
+func (s *Stats) Snapshot() models.TicketStats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	snapshot := models.TicketStats{
+		Attempts:       s.attempts,
+		TokenRefreshes: s.tokenRefreshes,
+		Codes:          make(map[string]int, len(s.codes)),
+		Latency:        make(map[string]models.LatencySummary, len(s.latency)),
+	}
+	for code, n := range s.codes {
+		snapshot.Codes[strconv.Itoa(code)] = n
+	}
+	for stage, samples := range s.latency {
+		sorted := slices.Clone(samples)
+		slices.Sort(sorted)
+		snapshot.Latency[stage] = models.LatencySummary{
+			Count: len(sorted),
+			P50:   percentile(sorted, 50),
+			P90:   percentile(sorted, 90),
+			P99:   percentile(sorted, 99),
+		}
+	}
+	return snapshot
+}
+
+// percentile 最近秩法, sorted 必须已排序
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,253 @@
+package ticket
+
+import (
+	client "bilibili-ticket-go/bili"
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/models"
+	"bilibili-ticket-go/models/errors"
+	"bilibili-ticket-go/notify"
+	"bilibili-ticket-go/utils"
+	"context"
+	errors2 "errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var watcherLogger = utils.GetLogger(global.GetLogger(), "watcher", nil)
+
+const (
+	DefaultWatchInterval = 5 * time.Minute
+	// MinWatchInterval 检查间隔的下限, 避免监控变成高频轮询
+	MinWatchInterval = time.Minute
+	// 同一轮检查中两个项目之间的间隔
+	watchProjectGap = 3 * time.Second
+)
+
+// Watcher 低频检查已关注项目的票种状态, 出现回流或新增场次时发送通知, 由人决定下一步
+type Watcher struct {
+	client   *client.Client
+	storage  *models.DataStorage
+	notify   notify.Notify
+	interval time.Duration
+
+	mutex    sync.Mutex
+	cancel   context.CancelFunc
+	onChange func()
+	trigger  chan struct{}
+}
+
+func NewWatcher(client *client.Client, storage *models.DataStorage, notify notify.Notify, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if interval < MinWatchInterval {
+		interval = MinWatchInterval
+	}
+	return &Watcher{
+		client:   client,
+		storage:  storage,
+		notify:   notify,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// SetChangeFunc 每次检查完一个项目后调用, 用于刷新界面
+func (w *Watcher) SetChangeFunc(f func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onChange = f
+}
+
+func (w *Watcher) Interval() time.Duration {
+	return w.interval
+}
+
+// Start 在后台开始定期检查
+func (w *Watcher) Start() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go w.loop(ctx)
+	watcherLogger.Infof("Availability watcher started, interval: %s", w.interval)
+}
+
+func (w *Watcher) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+}
+
+// Add 关注一个项目, 第一次检查只记录当前状态, 不会发送通知
+func (w *Watcher) Add(projectID int64, projectName string, skuIDs []int64) bool {
+	if err := w.storage.AddWatch(models.WatchEntry{
+		ProjectID:   projectID,
+		ProjectName: projectName,
+		SkuIDs:      skuIDs,
+	}); err != nil {
+		w.logStorageError(err)
+		return false
+	}
+	w.CheckNow()
+	return true
+}
+
+func (w *Watcher) Remove(projectID int64) bool {
+	if err := w.storage.RemoveWatch(projectID); err != nil {
+		w.logStorageError(err)
+		return false
+	}
+	w.changed()
+	return true
+}
+
+// CheckNow 让后台立即开始一轮检查
+func (w *Watcher) CheckNow() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) loop(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	w.checkAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.trigger:
+		}
+		w.checkAll(ctx)
+	}
+}
+
+func (w *Watcher) checkAll(ctx context.Context) {
+	for i, entry := range w.storage.GetWatches() {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchProjectGap):
+			}
+		}
+		w.check(entry)
+	}
+}
+
+func (w *Watcher) check(entry models.WatchEntry) {
+	logger := watcherLogger.WithField("project", entry.ProjectID)
+	err, info := w.client.GetProjectInformation(strconv.FormatInt(entry.ProjectID, 10))
+	if err != nil {
+		logger.Warnf("Failed to check project availability: %v", err)
+		return
+	}
+	current := make([]models.WatchSku, 0)
+	for _, s := range info.Screens {
+		for _, t := range s.Tickets {
+			current = append(current, models.WatchSku{
+				ScreenID:   s.ScreenID,
+				ScreenName: s.Name,
+				SkuID:      t.SkuID,
+				SkuName:    t.Desc,
+				Flag:       t.Flags.Number,
+				FlagName:   t.Flags.DisplayName,
+			})
+		}
+	}
+	changes := diffWatch(entry, current)
+	if err := w.storage.UpdateWatch(entry.ProjectID, info.ProjectName, current, time.Now()); err != nil {
+		var notFound *errors.EntryNotFoundError
+		if errors2.As(err, &notFound) {
+			// 检查期间被取消关注
+			return
+		}
+		logger.Errorf("Failed to save watch state: %v", err)
+	}
+	w.changed()
+	if len(changes) == 0 {
+		return
+	}
+	name := info.ProjectName
+	if name == "" {
+		name = entry.ProjectName
+	}
+	msg := fmt.Sprintf("余票监控：%s(%d)\n%s", name, entry.ProjectID, strings.Join(changes, "\n"))
+	logger.Warn(strings.ReplaceAll(msg, "\n", "; "))
+	if w.notify != nil {
+		w.notify.Notify(msg)
+	}
+}
+
+// diffWatch 比较两次检查的结果, 第一次检查没有历史状态时不产生变化
+func diffWatch(entry models.WatchEntry, current []models.WatchSku) []string {
+	if entry.LastCheck == 0 {
+		return nil
+	}
+	watched := func(skuID int64) bool {
+		return len(entry.SkuIDs) == 0 || slices.Contains(entry.SkuIDs, skuID)
+	}
+	knownScreens := make(map[int64]bool)
+	known := make(map[string]models.WatchSku)
+	for _, s := range entry.Known {
+		knownScreens[s.ScreenID] = true
+		known[watchKey(s)] = s
+	}
+	changes := make([]string, 0)
+	newScreens := make(map[int64]bool)
+	for _, s := range current {
+		if !knownScreens[s.ScreenID] {
+			if !newScreens[s.ScreenID] {
+				newScreens[s.ScreenID] = true
+				changes = append(changes, fmt.Sprintf("新增场次：%s", s.ScreenName))
+			}
+			continue
+		}
+		if !watched(s.SkuID) {
+			continue
+		}
+		old, ok := known[watchKey(s)]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("新增票种：%s %s [%s]", s.ScreenName, s.SkuName, s.FlagName))
+		} else if old.Flag != s.Flag {
+			changes = append(changes, fmt.Sprintf("%s %s：%s → %s", s.ScreenName, s.SkuName, old.FlagName, s.FlagName))
+		}
+	}
+	return changes
+}
+
+func watchKey(s models.WatchSku) string {
+	return fmt.Sprintf("%d/%d", s.ScreenID, s.SkuID)
+}
+
+// logStorageError 重复关注和已取消关注是正常情况, 只记录写入文件失败等错误
+func (w *Watcher) logStorageError(err error) {
+	var notFound *errors.EntryNotFoundError
+	var exists *errors.EntryExistsError
+	if errors2.As(err, &notFound) || errors2.As(err, &exists) {
+		return
+	}
+	watcherLogger.Errorf("Failed to save watch list: %v", err)
+}
+
+func (w *Watcher) changed() {
+	w.mutex.Lock()
+	f := w.onChange
+	w.mutex.Unlock()
+	if f != nil {
+		f()
+	}
+}
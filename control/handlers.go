@@ -0,0 +1,644 @@
+package control
+
+import (
+	"bilibili-ticket-go/bili/ticket"
+	"bilibili-ticket-go/models"
+	"bilibili-ticket-go/models/bili/api"
+	r "bilibili-ticket-go/models/bili/return"
+	"bilibili-ticket-go/models/enums"
+	bErrors "bilibili-ticket-go/models/errors"
+	"bilibili-ticket-go/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type buyerView struct {
+	Type int    `json:"type"`
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name"`
+	Tel  string `json:"tel,omitempty"`
+}
+
+type alternativeView struct {
+	ScreenID   int64  `json:"screen_id"`
+	ScreenName string `json:"screen_name,omitempty"`
+	SkuID      int64  `json:"sku_id"`
+	SkuName    string `json:"sku_name,omitempty"`
+}
+
+type resultView struct {
+	State    string     `json:"state"`
+	Code     int        `json:"code"`
+	Message  string     `json:"message"`
+	OrderID  int64      `json:"order_id,omitempty"`
+	ScreenID int64      `json:"screen_id,omitempty"`
+	SkuID    int64      `json:"sku_id,omitempty"`
+	Time     int64      `json:"time"`
+	Stats    *statsView `json:"stats,omitempty"`
+}
+
+type latencyView struct {
+	Count int   `json:"count"`
+	P50   int64 `json:"p50_ms"`
+	P90   int64 `json:"p90_ms"`
+	P99   int64 `json:"p99_ms"`
+}
+
+type statsView struct {
+	Attempts       int                    `json:"attempts"`
+	TokenRefreshes int                    `json:"token_refreshes"`
+	Codes          map[string]int         `json:"codes"`
+	Latency        map[string]latencyView `json:"latency"`
+}
+
+type ticketView struct {
+	ID           string            `json:"id"`
+	ProjectID    int64             `json:"project_id"`
+	ProjectName  string            `json:"project_name"`
+	ScreenID     int64             `json:"screen_id"`
+	ScreenName   string            `json:"screen_name"`
+	SkuID        int64             `json:"sku_id"`
+	SkuName      string            `json:"sku_name"`
+	Buyer        buyerView         `json:"buyer"`
+	Buyers       []buyerView       `json:"buyers"`
+	Alternatives []alternativeView `json:"alternatives"`
+	MaxPrice     int               `json:"max_price"`
+	AddressID    int64             `json:"address_id,omitempty"`
+	Start        int64             `json:"start"`
+	Expire       int64             `json:"expire"`
+	State        string            `json:"state"`
+	LastResult   resultView        `json:"last_result"`
+}
+
+type routineView struct {
+	ID     string     `json:"id"`
+	State  string     `json:"state"`
+	Result resultView `json:"result"`
+	Stats  statsView  `json:"stats"`
+}
+
+type eventView struct {
+	ID     string     `json:"id"`
+	From   string     `json:"from"`
+	To     string     `json:"to"`
+	Result resultView `json:"result"`
+	Time   int64      `json:"time"`
+}
+
+type addTicketRequest struct {
+	ProjectID int64       `json:"project_id"`
+	ScreenID  int64       `json:"screen_id"`
+	SkuID     int64       `json:"sku_id"`
+	Buyer     buyerView   `json:"buyer"`
+	Buyers    []buyerView `json:"buyers"` // 实名制项目一单多人, 省略时只使用 buyer
+	// 按优先级排列的备选场次和票种, 只需要 screen_id 和 sku_id
+	Alternatives []alternativeView `json:"alternatives"`
+	MaxPrice     int               `json:"max_price"`  // 单位为分, 省略时使用票种当前的价格
+	AddressID    int64             `json:"address_id"` // 纸质票的收货地址, 省略时使用默认地址
+}
+
+type addressView struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+	Default bool   `json:"default"`
+}
+
+type accountBuyerView struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	IdType   string `json:"id_type"`
+	IdCard   string `json:"id_card"`
+	Tel      string `json:"tel"`
+	Verified bool   `json:"verified"`
+	Status   int    `json:"status"`
+}
+
+type buyerIssueView struct {
+	ID      string `json:"id"`
+	BuyerID int64  `json:"buyer_id"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+}
+
+type buyerListView struct {
+	Buyers []accountBuyerView `json:"buyers"`
+	Issues []buyerIssueView   `json:"issues"`
+}
+
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tickets", s.listTickets)
+	mux.HandleFunc("POST /api/tickets", s.addTicket)
+	mux.HandleFunc("PUT /api/tickets/{id}", s.updateTicket)
+	mux.HandleFunc("DELETE /api/tickets/{id}", s.removeTicket)
+	mux.HandleFunc("POST /api/tickets/{id}/move", s.moveTicket)
+	mux.HandleFunc("GET /api/routines", s.listRoutines)
+	mux.HandleFunc("GET /api/routines/{id}", s.getRoutine)
+	mux.HandleFunc("POST /api/routines/{id}/start", s.startRoutine)
+	mux.HandleFunc("POST /api/routines/{id}/stop", s.stopRoutine)
+	mux.HandleFunc("POST /api/routines/{id}/pause", s.pauseRoutine)
+	mux.HandleFunc("POST /api/routines/{id}/resume", s.resumeRoutine)
+	mux.HandleFunc("GET /api/scheduler", s.schedulerStatus)
+	mux.HandleFunc("GET /api/clock", s.clockStatus)
+	mux.HandleFunc("GET /api/login", s.loginStatus)
+	mux.HandleFunc("GET /api/addresses", s.listAddresses)
+	mux.HandleFunc("GET /api/buyers", s.listBuyers)
+	mux.HandleFunc("GET /api/events", s.events)
+	return mux
+}
+
+func (s *Server) listTickets(w http.ResponseWriter, _ *http.Request) {
+	tickets := s.storage.GetTickets()
+	views := make([]ticketView, 0, len(tickets))
+	for _, t := range tickets {
+		views = append(views, newTicketView(t))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) addTicket(w http.ResponseWriter, req *http.Request) {
+	var body addTicketRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry, err := s.buildTicketEntry(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry.ID = models.NewTicketID()
+	if err := s.storage.AddTicket(entry); err != nil {
+		var exists *bErrors.EntryExistsError
+		if errors.As(err, &exists) {
+			writeError(w, http.StatusConflict, errors.New("ticket is already in the queue"))
+		} else {
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusCreated, newTicketView(entry))
+}
+
+// buildTicketEntry 根据项目/场次/票种 ID 从接口补全任务信息
+func (s *Server) buildTicketEntry(body addTicketRequest) (models.TicketEntry, error) {
+	pid := strconv.FormatInt(body.ProjectID, 10)
+	err, info := s.client.GetProjectInformation(pid)
+	if err != nil {
+		return models.TicketEntry{}, err
+	}
+	tickets := info.Tickets()
+	var selected *r.TicketSkuScreenID
+	for i, t := range tickets {
+		if t.ScreenID == body.ScreenID && t.SkuID == body.SkuID {
+			selected = &tickets[i]
+			break
+		}
+	}
+	if selected == nil {
+		return models.TicketEntry{}, fmt.Errorf("sku %d of screen %d not found in project %d", body.SkuID, body.ScreenID, body.ProjectID)
+	}
+	entry := models.TicketEntry{
+		ProjectID:   body.ProjectID,
+		ProjectName: info.ProjectName,
+		Expire:      selected.SaleStat.End.Unix(),
+		Start:       selected.SaleStat.Start.Unix(),
+		SkuID:       selected.SkuID,
+		SkuName:     selected.Desc,
+		ScreenID:    selected.ScreenID,
+		ScreenName:  selected.Name,
+		MaxPrice:    body.MaxPrice,
+	}
+	if entry.MaxPrice == 0 {
+		entry.MaxPrice = selected.Price
+	} else if entry.MaxPrice < selected.Price {
+		return models.TicketEntry{}, fmt.Errorf("max_price %d is lower than the ticket price %d", entry.MaxPrice, selected.Price)
+	}
+	needDelivery := selected.NeedDelivery
+	for _, want := range body.Alternatives {
+		index := slices.IndexFunc(tickets, func(t r.TicketSkuScreenID) bool {
+			return t.ScreenID == want.ScreenID && t.SkuID == want.SkuID
+		})
+		if index < 0 {
+			return models.TicketEntry{}, fmt.Errorf("alternative sku %d of screen %d not found in project %d", want.SkuID, want.ScreenID, body.ProjectID)
+		}
+		t := tickets[index]
+		if t.Price > entry.MaxPrice {
+			return models.TicketEntry{}, fmt.Errorf("alternative sku %d costs %d, more than max_price %d", t.SkuID, t.Price, entry.MaxPrice)
+		}
+		needDelivery = needDelivery || t.NeedDelivery
+		entry.Alternatives = append(entry.Alternatives, models.TicketAlternative{
+			ScreenID:   t.ScreenID,
+			ScreenName: t.Name,
+			SkuID:      t.SkuID,
+			SkuName:    t.Desc,
+		})
+	}
+	if needDelivery {
+		err, addresses := s.client.GetAddressList()
+		if err != nil {
+			return models.TicketEntry{}, err
+		}
+		for _, a := range addresses {
+			if (body.AddressID == 0 && a.Default == 1) || (body.AddressID != 0 && a.Id == body.AddressID) {
+				entry.AddressID = a.Id
+				break
+			}
+		}
+		if entry.AddressID == 0 {
+			return models.TicketEntry{}, errors.New("this ticket or an alternative ships by express, address_id is missing or not found")
+		}
+	}
+	if info.IsNeedContact {
+		entry.Buyer = r.TicketBuyer{
+			BuyerType: enums.Ordinary,
+			Name:      body.Buyer.Name,
+			Tel:       body.Buyer.Tel,
+		}
+	} else {
+		err, buyers := s.client.GetBuyerNoSensitiveInfo()
+		if err != nil {
+			return models.TicketEntry{}, err
+		}
+		wanted := body.Buyers
+		if len(wanted) == 0 {
+			wanted = []buyerView{body.Buyer}
+		}
+		if limit := ticket.BuyerLimit(s.client, body.ProjectID, selected.Limit); limit > 0 && len(wanted) > limit {
+			return models.TicketEntry{}, fmt.Errorf("at most %d buyers for sku %d", limit, selected.SkuID)
+		}
+		for _, want := range wanted {
+			index := slices.IndexFunc(buyers, func(b api.BuyerNoSensitiveStruct) bool { return b.Id == want.ID })
+			if index < 0 {
+				return models.TicketEntry{}, fmt.Errorf("buyer %d not found", want.ID)
+			}
+			entry.Buyers = append(entry.Buyers, r.TicketBuyer{
+				BuyerType: enums.ForceRealName,
+				ID:        buyers[index].Id,
+				Name:      buyers[index].Name,
+			})
+		}
+		entry.Buyer = entry.Buyers[0]
+	}
+	if !entry.Valid() {
+		return models.TicketEntry{}, errors.New("ticket data is invalid, check the buyer and the sale window")
+	}
+	return entry, nil
+}
+
+// updateTicket 用新的内容替换任务, ID, 状态和日志保持不变
+func (s *Server) updateTicket(w http.ResponseWriter, req *http.Request) {
+	var body addTicketRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	e, ok := s.manager.Get(req.PathValue("id"))
+	if !ok {
+		writeManagerError(w, bErrors.NewRoutineNotFoundError(req.PathValue("id")))
+		return
+	}
+	entry, err := s.buildTicketEntry(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = s.manager.Update(e.ID, entry); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	entry.ID = e.ID
+	writeJSON(w, http.StatusOK, newTicketView(entry))
+}
+
+type moveTicketRequest struct {
+	Offset int `json:"offset"` // 负数向前移动
+}
+
+func (s *Server) moveTicket(w http.ResponseWriter, req *http.Request) {
+	var body moveTicketRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.manager.Move(req.PathValue("id"), body.Offset); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeTicket(w http.ResponseWriter, req *http.Request) {
+	if err := s.manager.Remove(req.PathValue("id")); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listRoutines(w http.ResponseWriter, _ *http.Request) {
+	entries := s.manager.Entries()
+	views := make([]routineView, 0, len(entries))
+	for _, e := range entries {
+		views = append(views, newRoutineView(e))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) getRoutine(w http.ResponseWriter, req *http.Request) {
+	e, ok := s.manager.Get(req.PathValue("id"))
+	if !ok {
+		writeManagerError(w, bErrors.NewRoutineNotFoundError(req.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, newRoutineView(e))
+}
+
+func (s *Server) startRoutine(w http.ResponseWriter, req *http.Request) {
+	s.controlRoutine(w, req, s.manager.Start)
+}
+
+func (s *Server) stopRoutine(w http.ResponseWriter, req *http.Request) {
+	s.controlRoutine(w, req, s.manager.Stop)
+}
+
+func (s *Server) pauseRoutine(w http.ResponseWriter, req *http.Request) {
+	s.controlRoutine(w, req, s.manager.Pause)
+}
+
+func (s *Server) resumeRoutine(w http.ResponseWriter, req *http.Request) {
+	s.controlRoutine(w, req, s.manager.Resume)
+}
+
+func (s *Server) controlRoutine(w http.ResponseWriter, req *http.Request, action func(string) error) {
+	id := req.PathValue("id")
+	if err := action(id); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	e, ok := s.manager.Get(id)
+	if !ok {
+		writeManagerError(w, bErrors.NewRoutineNotFoundError(id))
+		return
+	}
+	writeJSON(w, http.StatusOK, newRoutineView(e))
+}
+
+func (s *Server) schedulerStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"offset_ms":  s.scheduler.GetGlobalOffset().Milliseconds(),
+		"task_count": s.scheduler.GetTaskCount(),
+		"tasks":      s.scheduler.GetTaskStatus(),
+	})
+}
+
+func (s *Server) clockStatus(w http.ResponseWriter, _ *http.Request) {
+	updated := s.scheduler.GetGlobalOffsetUpdatedAt()
+	status := map[string]any{
+		"offset_ms": s.scheduler.GetGlobalOffset().Milliseconds(),
+		"synced":    !updated.IsZero(),
+	}
+	if !updated.IsZero() {
+		status["synced_at"] = updated.Unix()
+		status["age_seconds"] = int64(time.Since(updated).Seconds())
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) loginStatus(w http.ResponseWriter, _ *http.Request) {
+	err, stat := s.client.GetLoginStatus()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"login": stat.Login,
+		"name":  stat.Name,
+		"uid":   stat.UID,
+	})
+}
+
+func (s *Server) listAddresses(w http.ResponseWriter, _ *http.Request) {
+	err, addresses := s.client.GetAddressList()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	views := make([]addressView, 0, len(addresses))
+	for _, a := range addresses {
+		views = append(views, addressView{
+			ID:      a.Id,
+			Name:    a.Name,
+			Phone:   a.Phone,
+			Address: a.FullAddress(),
+			Default: a.Default == 1,
+		})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// listBuyers 返回账号的购票人, 证件号和手机号已脱敏, 同时列出队列中有问题的购票人
+func (s *Server) listBuyers(w http.ResponseWriter, _ *http.Request) {
+	err, buyers := s.client.GetBuyerNoSensitiveInfo()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	view := buyerListView{
+		Buyers: make([]accountBuyerView, 0, len(buyers)),
+		Issues: make([]buyerIssueView, 0),
+	}
+	for _, b := range buyers {
+		view.Buyers = append(view.Buyers, accountBuyerView{
+			ID:       b.Id,
+			Name:     b.Name,
+			IdType:   b.IdName,
+			IdCard:   utils.MaskIDCard(b.IdCard),
+			Tel:      utils.MaskPhone(b.Tel),
+			Verified: b.Verified(),
+			Status:   b.Status,
+		})
+	}
+	for _, issue := range s.manager.CheckBuyers(buyers) {
+		view.Issues = append(view.Issues, buyerIssueView{
+			ID:      issue.ID,
+			BuyerID: issue.BuyerID,
+			Name:    issue.Name,
+			Reason:  issue.Reason,
+		})
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// events 以 Server-Sent Events 推送任务状态变更
+func (s *Server) events(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	ch := make(chan ticket.Event, 64)
+	unsubscribe := s.manager.Subscribe(func(event ticket.Event) {
+		select {
+		case ch <- event:
+		default:
+			// 客户端太慢, 丢弃事件而不是阻塞任务
+		}
+	})
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event := <-ch:
+			bs, err := json.Marshal(eventView{
+				ID:     event.ID,
+				From:   event.From.String(),
+				To:     event.To.String(),
+				Result: newResultView(event.Result, true),
+				Time:   event.Time.Unix(),
+			})
+			if err != nil {
+				logger.Warnf("Failed to marshal event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: state\ndata: %s\n\n", bs)
+			flusher.Flush()
+		}
+	}
+}
+
+func newTicketView(t models.TicketEntry) ticketView {
+	buyers := make([]buyerView, 0)
+	for _, b := range t.AllBuyers() {
+		buyers = append(buyers, buyerView{
+			Type: int(b.BuyerType),
+			ID:   b.ID,
+			Name: b.Name,
+			Tel:  b.Tel,
+		})
+	}
+	alternatives := make([]alternativeView, 0, len(t.Alternatives))
+	for _, a := range t.Alternatives {
+		alternatives = append(alternatives, alternativeView{
+			ScreenID:   a.ScreenID,
+			ScreenName: a.ScreenName,
+			SkuID:      a.SkuID,
+			SkuName:    a.SkuName,
+		})
+	}
+	return ticketView{
+		ID:          t.ID,
+		ProjectID:   t.ProjectID,
+		ProjectName: t.ProjectName,
+		ScreenID:    t.ScreenID,
+		ScreenName:  t.ScreenName,
+		SkuID:       t.SkuID,
+		SkuName:     t.SkuName,
+		Buyer: buyerView{
+			Type: int(t.Buyer.BuyerType),
+			ID:   t.Buyer.ID,
+			Name: t.Buyer.Name,
+			Tel:  t.Buyer.Tel,
+		},
+		Buyers:       buyers,
+		Alternatives: alternatives,
+		MaxPrice:     t.MaxPrice,
+		AddressID:    t.AddressID,
+		Start:        t.Start,
+		Expire:       t.Expire,
+		State:        t.State.String(),
+		LastResult:   newResultView(t.LastResult, false),
+	}
+}
+
+func newRoutineView(e *ticket.Entry) routineView {
+	return routineView{
+		ID:     e.ID,
+		State:  e.Routine.State().String(),
+		Result: newResultView(e.Routine.Result(), false),
+		Stats:  newStatsView(e.Routine.Stats()),
+	}
+}
+
+func newResultView(result models.TicketResult, withStats bool) resultView {
+	v := resultView{
+		State:    result.State.String(),
+		Code:     result.Code,
+		Message:  result.Message,
+		OrderID:  result.OrderID,
+		ScreenID: result.ScreenID,
+		SkuID:    result.SkuID,
+		Time:     result.Time,
+	}
+	if withStats {
+		stats := newStatsView(result.Stats)
+		v.Stats = &stats
+	}
+	return v
+}
+
+func newStatsView(stats models.TicketStats) statsView {
+	v := statsView{
+		Attempts:       stats.Attempts,
+		TokenRefreshes: stats.TokenRefreshes,
+		Codes:          stats.Codes,
+		Latency:        make(map[string]latencyView, len(stats.Latency)),
+	}
+	for stage, l := range stats.Latency {
+		v.Latency[stage] = latencyView{
+			Count: l.Count,
+			P50:   l.P50.Milliseconds(),
+			P90:   l.P90.Milliseconds(),
+			P99:   l.P99.Milliseconds(),
+		}
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warnf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeManagerError(w http.ResponseWriter, err error) {
+	var notFound *bErrors.RoutineNotFoundError
+	var stateErr *bErrors.RoutineStateError
+	var createErr *bErrors.RoutineCreateError
+	switch {
+	case errors.As(err, &notFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.As(err, &stateErr):
+		writeError(w, http.StatusConflict, err)
+	case errors.As(err, &createErr):
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
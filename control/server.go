@@ -0,0 +1,141 @@
+package control
+
+import (
+	client "bilibili-ticket-go/bili"
+	"bilibili-ticket-go/bili/ticket"
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/models"
+	"bilibili-ticket-go/scheduler"
+	"bilibili-ticket-go/utils"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var logger = utils.GetLogger(global.GetLogger(), "control", nil)
+
+const unixPrefix = "unix:"
+
+// Server 本地 HTTP 控制接口, 只监听回环地址或 unix socket, 所有请求都需要 token
+type Server struct {
+	network   string
+	address   string
+	token     string
+	manager   *ticket.Manager
+	storage   *models.DataStorage
+	client    *client.Client
+	scheduler *scheduler.DynamicScheduler
+	http      *http.Server
+	// closing 在 Shutdown 时关闭, 让事件流等长连接结束, 否则 Shutdown 会一直等待它们
+	closing chan struct{}
+}
+
+func NewServer(setting *models.ControlSetting, manager *ticket.Manager, storage *models.DataStorage, client *client.Client, scheduler *scheduler.DynamicScheduler) (*Server, error) {
+	if setting == nil || !setting.Enable {
+		return nil, errors.New("control server is disabled")
+	}
+	if setting.Token == "" {
+		return nil, errors.New("control server token is empty")
+	}
+	network, address, err := parseAddress(setting.Address)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		network:   network,
+		address:   address,
+		token:     setting.Token,
+		manager:   manager,
+		storage:   storage,
+		client:    client,
+		scheduler: scheduler,
+		closing:   make(chan struct{}),
+	}
+	s.http = &http.Server{
+		Handler:           s.authorize(s.routes()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.http.RegisterOnShutdown(func() { close(s.closing) })
+	return s, nil
+}
+
+// parseAddress 解析监听地址, TCP 地址只允许回环地址
+func parseAddress(address string) (string, string, error) {
+	if strings.HasPrefix(address, unixPrefix) {
+		path := strings.TrimPrefix(strings.TrimPrefix(address, unixPrefix), "//")
+		if path == "" {
+			return "", "", errors.New("control server unix socket path is empty")
+		}
+		return "unix", path, nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", err
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return "", "", fmt.Errorf("control server must listen on a loopback address, got: %s", host)
+		}
+	}
+	return "tcp", address, nil
+}
+
+// Start 开始监听, 请求在后台处理
+func (s *Server) Start() error {
+	if s.network == "unix" {
+		// 清理上次异常退出留下的 socket 文件
+		if _, err := os.Stat(s.address); err == nil {
+			if err := os.Remove(s.address); err != nil {
+				return err
+			}
+		}
+	}
+	var listener net.Listener
+	var err error
+	if s.network == "unix" {
+		listener, err = utils.ListenUnix(s.address)
+	} else {
+		listener, err = net.Listen(s.network, s.address)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infof("Control server listening on %s:%s", s.network, s.address)
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Control server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown 停止接受新连接, 结束事件流并等待其他请求完成, ctx 结束时强制关闭剩余的连接
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+	if err != nil {
+		s.http.Close()
+	}
+	return err
+}
+
+// authorize 校验 Authorization: Bearer <token>, EventSource 等无法设置请求头的客户端可以使用 ?token=
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
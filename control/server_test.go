@@ -0,0 +1,33 @@
+package control
+
+import (
+	"bilibili-ticket-go/bili/ticket"
+	"bilibili-ticket-go/models"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownEndsEventStreams(t *testing.T) {
+	s, err := NewServer(&models.ControlSetting{Enable: true, Address: "127.0.0.1:0", Token: "token"}, ticket.NewManager(nil, nil, nil, nil), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.http.Serve(listener)
+	resp, err := http.Get("http://" + listener.Addr().String() + "/api/events?token=token")
+	if err != nil {
+		t.Fatalf("GET /api/events error = %v", err)
+	}
+	defer resp.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v, want the event stream to end before the deadline", err)
+	}
+}
@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Attach 把当前终端连接到守护进程, 直到守护进程断开连接
+// 在 TUI 中按 Ctrl-C 只会断开客户端, 不会停止守护进程
+func Attach(path string) error {
+	stdin := int(os.Stdin.Fd())
+	stdout := int(os.Stdout.Fd())
+	if !term.IsTerminal(stdin) {
+		return errors.New("attach requires an interactive terminal")
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return fmt.Errorf("no daemon is listening on %s: %w", path, err)
+	}
+	defer conn.Close()
+	width, height, err := term.GetSize(stdout)
+	if err != nil {
+		return err
+	}
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(stdin, state)
+	if err = writeFrame(conn, frameHello, encodeHello(hello{
+		Term:   os.Getenv("TERM"),
+		Width:  width,
+		Height: height,
+	})); err != nil {
+		return err
+	}
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if err = writeFrame(conn, frameInput, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		// 轮询窗口大小, 在所有平台上都可用
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			w, h, err := term.GetSize(stdout)
+			if err != nil || (w == width && h == height) {
+				continue
+			}
+			width, height = w, h
+			if err = writeFrame(conn, frameResize, encodeSize(w, h)); err != nil {
+				return
+			}
+		}
+	}()
+	var msg string
+	for {
+		kind, payload, err := readFrame(conn)
+		if err != nil {
+			break
+		}
+		switch kind {
+		case frameOutput:
+			os.Stdout.Write(payload)
+		case frameMessage:
+			msg = string(payload)
+		}
+	}
+	term.Restore(stdin, state)
+	if msg != "" {
+		fmt.Println(msg)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 帧格式: [类型 1 字节][长度 4 字节, 大端][内容]
+const (
+	frameHello   byte = iota + 1 // 客户端 -> 守护进程: 终端类型和窗口大小
+	frameInput                   // 客户端 -> 守护进程: 键盘输入
+	frameResize                  // 客户端 -> 守护进程: 窗口大小变化
+	frameOutput                  // 守护进程 -> 客户端: 终端输出
+	frameMessage                 // 守护进程 -> 客户端: 断开前显示给用户的提示
+)
+
+const maxFrameSize = 1 << 20
+
+type hello struct {
+	Term   string
+	Width  int
+	Height int
+}
+
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, errors.New("frame is too large")
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+func encodeSize(width, height int) []byte {
+	bs := make([]byte, 4)
+	binary.BigEndian.PutUint16(bs[0:], uint16(width))
+	binary.BigEndian.PutUint16(bs[2:], uint16(height))
+	return bs
+}
+
+func decodeSize(bs []byte) (int, int, error) {
+	if len(bs) < 4 {
+		return 0, 0, errors.New("invalid window size")
+	}
+	return int(binary.BigEndian.Uint16(bs[0:])), int(binary.BigEndian.Uint16(bs[2:])), nil
+}
+
+func encodeHello(h hello) []byte {
+	return append(encodeSize(h.Width, h.Height), h.Term...)
+}
+
+func decodeHello(bs []byte) (hello, error) {
+	width, height, err := decodeSize(bs)
+	if err != nil {
+		return hello{}, err
+	}
+	return hello{
+		Term:   string(bs[4:]),
+		Width:  width,
+		Height: height,
+	}, nil
+}
@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/utils"
+	"errors"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var logger = utils.GetLogger(global.GetLogger(), "daemon", nil)
+
+// Server 让 TUI 客户端通过 unix socket 连接到守护进程中的 tview 应用
+// 同一时间只有一个客户端, 新客户端连接时旧客户端会被断开; 没有客户端时应用渲染到模拟屏幕上
+type Server struct {
+	app      *tview.Application
+	path     string
+	listener net.Listener
+	mutex    sync.Mutex
+	current  *remoteTty
+}
+
+func NewServer(app *tview.Application, path string) *Server {
+	return &Server{
+		app:  app,
+		path: path,
+	}
+}
+
+// DetachedScreen 没有客户端连接时使用的屏幕
+func DetachedScreen() tcell.Screen {
+	return tcell.NewSimulationScreen("UTF-8")
+}
+
+// Start 开始监听 socket
+func (s *Server) Start() error {
+	if conn, err := net.Dial("unix", s.path); err == nil {
+		conn.Close()
+		return errors.New("another daemon is listening on " + s.path)
+	}
+	if _, err := os.Stat(s.path); err == nil {
+		// 上次异常退出留下的 socket 文件
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+	}
+	listener, err := utils.ListenUnix(s.path)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	logger.Infof("Daemon listening on %s", s.path)
+	go s.accept()
+	return nil
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Errorf("Daemon accept error: %v", err)
+			}
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	kind, payload, err := readFrame(conn)
+	if err != nil || kind != frameHello {
+		conn.Close()
+		return
+	}
+	h, err := decodeHello(payload)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	ti, err := tcell.LookupTerminfo(h.Term)
+	if err != nil {
+		_ = writeFrame(conn, frameMessage, []byte("Unsupported terminal: "+h.Term))
+		conn.Close()
+		return
+	}
+	tty := newRemoteTty(conn, h.Width, h.Height)
+	screen, err := tcell.NewTerminfoScreenFromTtyTerminfo(tty, ti)
+	if err != nil {
+		tty.message(err.Error())
+		conn.Close()
+		return
+	}
+	s.mutex.Lock()
+	previous := s.current
+	s.current = tty
+	if previous != nil {
+		previous.message("Another client attached to the daemon.")
+	}
+	s.app.SetScreen(screen)
+	s.mutex.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	logger.Info("Client attached")
+	for {
+		kind, payload, err := readFrame(conn)
+		if err != nil {
+			break
+		}
+		switch kind {
+		case frameInput:
+			tty.input <- payload
+		case frameResize:
+			if width, height, err := decodeSize(payload); err == nil {
+				tty.setSize(width, height)
+			}
+		}
+	}
+	s.detach(tty, "")
+}
+
+// Detach 断开当前客户端, 任务继续在守护进程中运行
+func (s *Server) Detach() {
+	s.mutex.Lock()
+	current := s.current
+	s.mutex.Unlock()
+	if current != nil {
+		s.detach(current, "Detached, the daemon is still running.")
+	}
+}
+
+func (s *Server) detach(tty *remoteTty, msg string) {
+	s.mutex.Lock()
+	if s.current != tty {
+		s.mutex.Unlock()
+		return
+	}
+	s.current = nil
+	// Fini 会关闭连接, 提示要在切换屏幕前发送, 客户端恢复终端后再显示
+	if msg != "" {
+		tty.message(msg)
+	}
+	// 旧屏幕会在这里被 Fini, 客户端终端随之恢复
+	s.app.SetScreen(DetachedScreen())
+	s.mutex.Unlock()
+	tty.Close()
+	logger.Info("Client detached")
+}
+
+func (s *Server) Close() error {
+	s.Detach()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"net"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// remoteTty 把一个客户端连接包装成 tcell.Tty
+// 连接断开时 Read 不会返回错误, 否则 tview 会把它当作致命错误退出, 由 Server 负责切换屏幕
+type remoteTty struct {
+	conn       net.Conn
+	writeMutex sync.Mutex
+	input      chan []byte
+	pending    []byte
+
+	mutex   sync.Mutex
+	size    tcell.WindowSize
+	resize  func()
+	drained chan struct{}
+}
+
+func newRemoteTty(conn net.Conn, width, height int) *remoteTty {
+	return &remoteTty{
+		conn:    conn,
+		input:   make(chan []byte, 64),
+		size:    tcell.WindowSize{Width: width, Height: height},
+		drained: make(chan struct{}),
+	}
+}
+
+func (t *remoteTty) Start() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	select {
+	case <-t.drained:
+		t.drained = make(chan struct{})
+	default:
+	}
+	return nil
+}
+
+func (t *remoteTty) Stop() error {
+	return nil
+}
+
+// Drain 唤醒阻塞中的 Read
+func (t *remoteTty) Drain() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	select {
+	case <-t.drained:
+	default:
+		close(t.drained)
+	}
+	return nil
+}
+
+func (t *remoteTty) NotifyResize(cb func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.resize = cb
+}
+
+func (t *remoteTty) WindowSize() (tcell.WindowSize, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.size, nil
+}
+
+func (t *remoteTty) setSize(width, height int) {
+	t.mutex.Lock()
+	t.size = tcell.WindowSize{Width: width, Height: height}
+	cb := t.resize
+	t.mutex.Unlock()
+	if cb != nil {
+		cb()
+	}
+}
+
+func (t *remoteTty) Read(p []byte) (int, error) {
+	if len(t.pending) == 0 {
+		t.mutex.Lock()
+		drained := t.drained
+		t.mutex.Unlock()
+		select {
+		case bs := <-t.input:
+			t.pending = bs
+		case <-drained:
+			return 0, nil
+		}
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// Write 连接断开后的输出直接丢弃
+func (t *remoteTty) Write(p []byte) (int, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	_ = writeFrame(t.conn, frameOutput, p)
+	return len(p), nil
+}
+
+func (t *remoteTty) Close() error {
+	return t.conn.Close()
+}
+
+// message 在断开前发送一条提示给客户端
+func (t *remoteTty) message(msg string) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	_ = writeFrame(t.conn, frameMessage, []byte(msg))
+}
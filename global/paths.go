@@ -0,0 +1,28 @@
+package global
+
+import "path/filepath"
+
+// 配置文件和运行数据所在的目录, 启动时由 SetDirs 设置, 默认为工作目录
+var (
+	configDir = "."
+	stateDir  = "."
+)
+
+// SetDirs 设置配置目录(config.json)和状态目录(data.json, 日志, 锁文件)
+func SetDirs(config string, state string) {
+	configDir = config
+	stateDir = state
+}
+
+func ConfigDir() string {
+	return configDir
+}
+
+func StateDir() string {
+	return stateDir
+}
+
+// StatePath 返回状态目录下的路径
+func StatePath(elem ...string) string {
+	return filepath.Join(append([]string{stateDir}, elem...)...)
+}
@@ -4,7 +4,10 @@ import (
 	client "bilibili-ticket-go/bili"
 	"bilibili-ticket-go/bili/ticket"
 	"bilibili-ticket-go/clock"
+	"bilibili-ticket-go/control"
+	"bilibili-ticket-go/daemon"
 	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/metrics"
 	"bilibili-ticket-go/models"
 	"bilibili-ticket-go/models/bili/api"
 	"bilibili-ticket-go/models/bili/return"
@@ -17,11 +20,15 @@ import (
 	"bilibili-ticket-go/tui/primitives"
 	tutils "bilibili-ticket-go/tui/utils"
 	"bilibili-ticket-go/utils"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DeRuina/timberjack"
@@ -34,21 +41,16 @@ import (
 	_ "bilibili-ticket-go/captcha"
 )
 
-type ticketRoutineInformation struct {
-	routine  *ticket.Routine
-	logCache *hooks.LoggerCache
-}
-
 var (
-	logger         = utils.GetLogger(global.GetLogger(), "main", nil)
-	biliClient     *client.Client
-	conf           *models.Configuration
-	data           *models.DataStorage
-	jar            *cookiejar.Jar
-	app            *tview.Application
-	loggerTextview *tview.TextView
-	fileLogger     = &timberjack.Logger{
-		Filename:         "logs/latest.log",
+	logger     = utils.GetLogger(global.GetLogger(), "main", nil)
+	biliClient *client.Client
+	conf       *models.Configuration
+	data       *models.DataStorage
+	jar        *cookiejar.Jar
+	app        *tview.Application
+	logBuffer  *hooks.LogBuffer
+	// 文件名在 setup 中根据数据目录设置
+	fileLogger = &timberjack.Logger{
 		MaxSize:          100, // megabytes
 		MaxBackups:       30,  // backups
 		MaxAge:           7,   // days
@@ -56,36 +58,61 @@ var (
 		LocalTime:        true,
 		BackupTimeFormat: "20060102-150405",
 	}
-	ticketRoutineInfo               = make(map[string]*ticketRoutineInformation)
-	successTicketTask               = make(map[string]bool)
-	schedulerManager                = scheduler.NewDynamicScheduler()
-	notifyManager     notify.Notify = nil
+	schedulerManager               = scheduler.NewDynamicScheduler()
+	notifyManager    notify.Notify = nil
+	ticketManager    *ticket.Manager
+	ticketWatcher    *ticket.Watcher
+	sessionMonitor   *ticket.SessionMonitor
+	// sessionSaver 合并普通 Cookie 的变化, 不在每个请求中同步写配置文件
+	sessionSaver *utils.Debouncer
+)
+
+var (
+	daemonMode  = flag.Bool("daemon", false, "run without a terminal and accept TUI clients on the daemon socket")
+	attachMode  = flag.Bool("attach", false, "attach this terminal to a running daemon")
+	socketPath  = flag.String("socket", "", "path of the daemon socket, defaults to daemon.sock in the data directory")
+	unredacted  = flag.Bool("unredacted", false, "do not mask credentials, phone numbers and ID numbers in logs, for debugging only")
+	dataDir     = flag.String("data-dir", "", "directory for config.json, data.json and logs, defaults to the XDG config and state directories (env "+dataDirEnv+")")
+	projectLink = flag.String("project", "", "open a project ID, show.bilibili.com URL or b23.tv link in the ticket page")
 )
 
-func init() {
+// unredactPeriod 在设置页临时关闭日志脱敏的时长
+const unredactPeriod = 10 * time.Minute
+
+// sessionSaveDelay 普通 Cookie 变化后延迟保存的时间
+const sessionSaveDelay = 5 * time.Second
+
+// sessionCookies 登录凭证, 变化时立即保存
+var sessionCookies = []string{"SESSDATA", "bili_jct"}
+
+// logBufferSize 日志页保留的记录数, 打开日志文件时也只读取最后这么多条
+const logBufferSize = 10000
+
+// instanceLockFile 放在状态目录中, 保证同一个目录只有一个实例在读写 config.json 和 data.json
+const instanceLockFile = "instance.lock"
+
+func setup() error {
+	fileLogger.Filename = global.StatePath("logs", "latest.log")
 	global.GetLogger().AddHook(hooks.NewLogFileRotateHook(fileLogger))
-	if st, err := os.Stat("logs/latest.log"); !utils.IsFileEmpty("logs/latest.log") && err != nil && st.Size() >= int64(fileLogger.MaxSize)*1000*1000 {
+	if st, err := os.Stat(fileLogger.Filename); !utils.IsFileEmpty(fileLogger.Filename) && err == nil && st.Size() >= int64(fileLogger.MaxSize)*1000*1000 {
 		fileLogger.Rotate()
 	}
-	loggerTextview = tview.NewTextView()
-	loggerTextview.SetDynamicColors(true).
-		SetScrollable(true).
-		SetMaxLines(2000).
-		SetChangedFunc(func() {
-			if app != nil {
-				app.Draw()
-			}
-		})
-	global.GetLogger().SetOutput(tview.ANSIWriter(loggerTextview))
+	// 日志页从缓冲区读取结构化的记录, 终端输出由日志页负责
+	logBuffer = hooks.NewLogBuffer(logBufferSize)
+	global.GetLogger().AddHook(logBuffer)
+	global.GetLogger().SetOutput(io.Discard)
 	req.SetDefaultClient(req.DefaultClient().SetLogger(utils.GetLogger(global.GetLogger(), "network", nil)).EnableDebugLog())
 	var err error
 	conf, err = models.NewConfiguration()
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("load config: %w", err)
+	}
+	if err = utils.SetFileLogFormat(conf.Log.Format); err != nil {
+		return fmt.Errorf("load config: %w", err)
 	}
 	data, err = models.NewDataStorage()
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("load data: %w", err)
 	}
 	jar = cookiejar.New(&cookiejar.Options{
 		PublicSuffixList: nil,
@@ -95,15 +122,99 @@ func init() {
 	conf.Bilibili.BUVID = biliClient.GetBUVID()
 	conf.Bilibili.Fingerprint = biliClient.GetFingerprint()
 	conf.Bilibili.InfocUUID = biliClient.GetInfocUUID()
+	sessionSaver = utils.NewDebouncer(sessionSaveDelay, func() { saveSession() })
+	jar.SetChangeFunc(func(names []string) {
+		// 刷新 Cookie 后旧的登录凭证马上会被注销, 必须先写入文件; 其他 Cookie 的变化延迟合并保存
+		for _, name := range names {
+			if slices.Contains(sessionCookies, name) {
+				saveSession()
+				return
+			}
+		}
+		sessionSaver.Trigger()
+	})
+	// 同上, refresh token 变化后旧的 token 会被注销
+	biliClient.SetRefreshTokenChangeFunc(func() { saveSession() })
 	switch enums.ConvertNotificationType(conf.Ticket.Notification.Type) {
 	case enums.None:
 		notifyManager = nil
 	case enums.Gotify:
 		notifyManager = notify.NewGotify(conf.Ticket.Notification.Token, conf.Ticket.Notification.Endpoint)
 	}
+	ticketManager = ticket.NewManager(biliClient, data, schedulerManager, notifyManager)
+	ticketManager.AddLogHook(logBuffer)
+	ticketWatcher = ticket.NewWatcher(biliClient, data, notifyManager, time.Duration(conf.Ticket.WatchInterval)*time.Minute)
+	sessionMonitor = ticket.NewSessionMonitor(biliClient, notifyManager, time.Duration(conf.Ticket.SessionCheckInterval)*time.Minute, func() { saveSession() })
+	return nil
+}
+
+// saveSession 把当前的 Cookie 和 refresh token 写回配置文件, 登录凭证变化时直接调用, 其他 Cookie 变化时由 sessionSaver 调用
+func saveSession() error {
+	err := conf.SaveSession(jar.AllPersistentEntries(), biliClient.GetRefreshToken())
+	if err != nil {
+		logger.Errorf("Failed to save session: %v", err)
+	}
+	return err
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run 返回进程的退出码, 所有 defer 都在 os.Exit 之前执行
+func run() (code int) {
+	flag.Parse()
+	if *daemonMode {
+		ignoreHangup()
+	}
+	configDir, stateDir, err := resolveDirs(*dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to resolve the data directory: %v\n", err)
+		return 1
+	}
+	global.SetDirs(configDir, stateDir)
+	if *socketPath == "" {
+		*socketPath = global.StatePath("daemon.sock")
+	}
+	if *attachMode {
+		if err := daemon.Attach(*socketPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	for _, dir := range []string{configDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create the data directory: %v\n", err)
+			return 1
+		}
+	}
+	lock, err := utils.AcquireInstanceLock(global.StatePath(instanceLockFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to lock the data directory: %v\n", err)
+		return 1
+	}
+	defer lock.Release()
+	// 日志在 setup 之后才写入文件, 迁移结果之后再输出
+	migrated, migrateErr := migrateWorkingDir(configDir, stateDir)
+	if err := setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if lock.StalePID > 0 {
+		logger.Warnf("The previous instance (PID %d) did not exit cleanly, its lock has been taken over.", lock.StalePID)
+	}
+	for _, m := range migrated {
+		logger.Infof("Migrated %s", m)
+	}
+	if migrateErr != nil {
+		logger.Warnf("Failed to migrate files from the working directory: %v", migrateErr)
+	}
+	logger.Infof("Config directory: %s, state directory: %s", configDir, stateDir)
+	if *unredacted {
+		utils.DisableRedaction(0)
+		logger.Warn("Log redaction is disabled, logs may contain credentials and personal information.")
+	}
 	/*	bc, err := clock.GetBilibiliClockOffset()
 		if err != nil {
 			logger.Warn("Failed to get Bilibili clock offset: ", err)
@@ -117,24 +228,11 @@ func main() {
 			logger.Trace("The Offset Between You and Aliyun NTP Server: ", ac)
 		}*/
 	defer fileLogger.Close()
+	// 在其他 defer 之后执行, 此时余票监控和会话监控已停止, 控制接口和指标接口在 shutdown 中停止
 	defer func() {
-		var ck = jar.AllPersistentEntries()
-		if ck != nil {
-			conf.Bilibili.Cookies = ck
-		}
-		t := biliClient.GetRefreshToken()
-		if t != "" {
-			conf.Bilibili.RefreshToken = t
+		if !shutdown() && code == 0 {
+			code = 1
 		}
-		conf.Save()
-	}()
-	defer func() {
-		for s, b := range successTicketTask {
-			if b {
-				data.RemoveTicketByHash(s)
-			}
-		}
-		data.Save()
 	}()
 	defer func() {
 		if p := recover(); p != nil {
@@ -153,13 +251,183 @@ func main() {
 		AddItem(featureChoose, 25, 1, false).
 		AddItem(functionPages, 0, 4, false)
 	k := keyboard.NewKeyboardCaptureInstance(app, flex)
+	// openProject 在购票页打开指定项目, 由购票页设置, 供搜索页使用
+	var openProject func(projectID string)
+	// openBuyers 进入购票人页时刷新列表
+	var openBuyers func()
+	// openLogs 进入日志页时刷新日志文件列表
+	var openLogs func()
+	// editTicket 在购票页编辑已排队的任务, 由购票页设置, 供任务列表使用
+	var editTicket func(t models.TicketEntry)
 	{
 		{
-			loggerTextview.ScrollToEnd()
-			functionPages.AddPage("logs",
-				tview.NewFlex().SetDirection(tview.FlexRow).AddItem(loggerTextview, 0, 1, false),
-				true,
-				false)
+			var (
+				view         = tview.NewTextView()
+				sourceList   = primitives.NewDropDown()
+				levelList    = primitives.NewDropDown()
+				compList     = primitives.NewDropDown()
+				searchInput  = tview.NewInputField()
+				followBtn    = tview.NewButton("Pause")
+				statusLabel  = tview.NewTextView()
+				records      []hooks.LogRecord // 当前来源的全部记录, 过滤只影响显示
+				lastSeq      uint64
+				live         = true
+				follow       = true
+				pending      atomic.Bool
+				filter       = tutils.LogFilter{Level: logrus.TraceLevel}
+				components   []string
+				updatingComp bool
+			)
+			levels := []logrus.Level{logrus.ErrorLevel, logrus.WarnLevel, logrus.InfoLevel, logrus.DebugLevel, logrus.TraceLevel}
+			view.SetDynamicColors(true).SetScrollable(true).SetMaxLines(logBufferSize)
+			updateStatus := func() {
+				source := "live"
+				if !live {
+					_, source = sourceList.GetCurrentOption()
+				}
+				state := "following"
+				if !follow {
+					state = "paused"
+				}
+				statusLabel.SetText(fmt.Sprintf("%d records from %s, %s", len(records), source, state))
+			}
+			// updateComponents 出现新组件时重建组件列表, 保持当前选择
+			updateComponents := func() {
+				found := tutils.LogComponents(records)
+				if slices.Equal(found, components) {
+					return
+				}
+				components = found
+				updatingComp = true
+				compList.SetOptions(append([]string{"All"}, components...), nil)
+				current := 0
+				if i := slices.Index(components, filter.Component); i >= 0 {
+					current = i + 1
+				}
+				compList.SetCurrentOption(current)
+				updatingComp = false
+			}
+			render := func() {
+				view.SetText(tutils.FilterLogRecords(records, filter))
+				if follow {
+					view.ScrollToEnd()
+				}
+				updateStatus()
+			}
+			// pull 把缓冲区中的新记录追加到视图, 只在 UI 线程调用
+			pull := func() {
+				pending.Store(false)
+				if !live {
+					return
+				}
+				added := logBuffer.Since(lastSeq)
+				if len(added) == 0 {
+					return
+				}
+				lastSeq = added[len(added)-1].Seq
+				records = append(records, added...)
+				if len(records) > logBufferSize {
+					records = slices.Clone(records[len(records)-logBufferSize:])
+				}
+				updateComponents()
+				if follow {
+					fmt.Fprint(view, tutils.FilterLogRecords(added, filter))
+					view.ScrollToEnd()
+				}
+				updateStatus()
+			}
+			loadSource := func(option string, index int) {
+				if index <= 0 {
+					live = true
+					records = logBuffer.Since(0)
+					lastSeq = 0
+					if len(records) > 0 {
+						lastSeq = records[len(records)-1].Seq
+					}
+				} else {
+					live = false
+					records = nil
+					f, err := os.Open(global.StatePath("logs", option))
+					if err != nil {
+						logger.Errorf("Failed to open log file %s: %v", option, err)
+					} else {
+						records, err = hooks.ParseLogRecords(f, logBufferSize)
+						f.Close()
+						if err != nil {
+							logger.Errorf("Failed to read log file %s: %v", option, err)
+						}
+					}
+				}
+				updateComponents()
+				render()
+			}
+			refreshSources := func() {
+				current, _ := sourceList.GetCurrentOption()
+				options := append([]string{"Live"}, tutils.ListLogFiles(global.StatePath("logs"))...)
+				sourceList.SetOptions(options, loadSource)
+				if current < 0 || current >= len(options) {
+					current = 0
+				}
+				sourceList.SetCurrentOption(current)
+			}
+			levelOptions := make([]string, 0, len(levels))
+			for _, l := range levels {
+				levelOptions = append(levelOptions, strings.ToUpper(l.String()))
+			}
+			levelList.SetLabel("Level: ").SetOptions(levelOptions, func(_ string, index int) {
+				filter.Level = levels[index]
+				render()
+			})
+			levelList.SetCurrentOption(len(levels) - 1)
+			compList.SetLabel("Component: ").SetSelectedFunc(func(text string, index int) {
+				if updatingComp {
+					return
+				}
+				filter.Component = ""
+				if index > 0 {
+					filter.Component = text
+				}
+				render()
+			})
+			sourceList.SetLabel("Source: ")
+			searchInput.SetLabel("Search: ").SetChangedFunc(func(text string) {
+				filter.Search = text
+				render()
+			})
+			followBtn.SetSelectedFunc(func() {
+				follow = !follow
+				if follow {
+					followBtn.SetLabel("Pause")
+					pull()
+					render()
+				} else {
+					followBtn.SetLabel("Follow")
+					updateStatus()
+				}
+			})
+			// 记录在任意 goroutine 中追加, 合并为一次 UI 更新
+			logBuffer.SetAppendFunc(func() {
+				if pending.CompareAndSwap(false, true) {
+					go app.QueueUpdateDraw(pull)
+				}
+			})
+			refreshSources()
+			openLogs = refreshSources
+			root := tview.NewFlex().SetDirection(tview.FlexRow)
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(sourceList, 0, 2, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(levelList, 17, 0, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(compList, 0, 1, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(searchInput, 0, 2, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(followBtn, 8, 0, false),
+				1, 0, false)
+			root.AddItem(view, 0, 1, false)
+			root.AddItem(statusLabel, 1, 0, false)
+			functionPages.AddPage("logs", root, true, false)
 		}
 		{
 			root := tview.NewFlex().SetDirection(tview.FlexRow)
@@ -175,13 +443,6 @@ func main() {
 			}
 			if stat.Login {
 				t.Write([]byte(fmt.Sprintf("Welcome %s, Your UID is %d", stat.Name, stat.UID)))
-				err, f := biliClient.CheckAndUpdateCookie()
-				if f {
-					logger.Trace("Refresh cookie successfully.")
-				}
-				if err != nil {
-					logger.Errorf("CheckAndUpdateCookie error: %v", err)
-				}
 			} else {
 				t.Write([]byte("You are not logged in. Please login first."))
 				qrv := tview.NewTextView().SetChangedFunc(func() { app.Draw() })
@@ -245,6 +506,8 @@ func main() {
 										t.Clear()
 										t.Write([]byte(fmt.Sprintf("Welcome %s, Your UID is %d", stat.Name, stat.UID)))
 										b = true
+										saveSession()
+										sessionMonitor.CheckNow()
 									} else {
 										root.AddItem(btn, 3, 0, false)
 									}
@@ -280,21 +543,107 @@ func main() {
 				mutex          = sync.Mutex{} // Mutex to protect shared data
 				projID         string
 				buyers         []api.BuyerNoSensitiveStruct
-				buyer          api.BuyerNoSensitiveStruct
+				chosenBuyers   []int // buyers 中已选中的下标
+				buyerLimit     int   // 票种限购数和项目一单最多购票人数中较小的一个, 0 表示未限制
+				fallbacks      []int // tickets 中按优先级排列的备选下标
 				buyerType      = enums.ForceRealName
 				projName       string
+				addresses      []api.AddressStruct
+				address        api.AddressStruct
+				editingID      string // 正在编辑的任务 ID, 为空时添加新任务
 			)
 			var (
-				ticketList        = primitives.NewDropDown()
-				buyerList         = primitives.NewDropDown()
-				buyerTelInput     = primitives.NewInputField()
-				buyerNameInput    = primitives.NewInputField()
-				buyerFlex         = tview.NewFlex().SetDirection(tview.FlexRow)
-				input             *tview.InputField
-				addToQueueBtn     *tview.Button
-				buyerSelectedFunc = func(text string, index int) {
-					buyer = buyers[index]
-					addToQueueBtn.SetDisabled(false)
+				ticketList     = primitives.NewDropDown()
+				buyerBtn       *tview.Button
+				buyerLabel     = primitives.NewLabel("")
+				fallbackBtn    *tview.Button
+				fallbackLabel  = primitives.NewLabel("")
+				buyerTelInput  = primitives.NewInputField()
+				buyerNameInput = primitives.NewInputField()
+				maxPriceInput  = primitives.NewInputField()
+				addressList    = primitives.NewDropDown()
+				buyerFlex      = tview.NewFlex().SetDirection(tview.FlexRow)
+				input          *tview.InputField
+				addToQueueBtn  *tview.Button
+				watchBtn       *tview.Button
+				projectDetail  = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+				setEditingFunc = func(id string) {
+					editingID = id
+					if id == "" {
+						addToQueueBtn.SetLabel(" Add to Automatic Ticket Booking Queue ")
+					} else {
+						addToQueueBtn.SetLabel(fmt.Sprintf(" Save Changes to Task %s ", id[0:9]))
+					}
+				}
+				buyerChosenFunc = func(selected []int) {
+					chosenBuyers = selected
+					names := make([]string, 0, len(selected))
+					for _, i := range selected {
+						names = append(names, buyers[i].Name)
+					}
+					if len(names) == 0 {
+						buyerLabel.SetText("No buyer selected")
+					} else {
+						buyerLabel.SetText(strings.Join(names, ", "))
+					}
+					addToQueueBtn.SetDisabled(len(selected) == 0)
+				}
+				fallbackChosenFunc = func(selected []int) {
+					fallbacks = selected
+					names := make([]string, 0, len(selected))
+					for _, i := range selected {
+						names = append(names, fmt.Sprintf("%s-%s", tickets[i].Name, tickets[i].Desc))
+					}
+					fallbackLabel.SetText(strings.Join(names, " > "))
+				}
+				// chooseFallbacksFunc 首选票种不可售时按选择的顺序尝试其他票种
+				chooseFallbacksFunc = func() {
+					mutex.Lock()
+					defer mutex.Unlock()
+					var (
+						options    []string
+						candidates []int
+						selected   []int
+					)
+					for i, t := range tickets {
+						if t.SkuID == selectedTicket.SkuID && t.ScreenID == selectedTicket.ScreenID {
+							continue
+						}
+						options = append(options, fmt.Sprintf("%s-%s ¥%s", t.Name, t.Desc, tutils.FormatPrice(t.Price)))
+						candidates = append(candidates, i)
+					}
+					for _, f := range fallbacks {
+						selected = append(selected, slices.Index(candidates, f))
+					}
+					tutils.MultiSelectModal("Fallbacks", options, selected, 0, mainPages, func(selected []int) {
+						mutex.Lock()
+						defer mutex.Unlock()
+						chosen := make([]int, 0, len(selected))
+						for _, i := range selected {
+							chosen = append(chosen, candidates[i])
+						}
+						fallbackChosenFunc(chosen)
+					}, k)
+				}
+				// chooseBuyersFunc 实名制项目一单可以有多个购票人, 数量不超过 buyerLimit
+				chooseBuyersFunc = func() {
+					mutex.Lock()
+					defer mutex.Unlock()
+					if len(buyers) == 0 {
+						return
+					}
+					options := make([]string, 0, len(buyers))
+					for _, b := range buyers {
+						options = append(options, fmt.Sprintf("%s-%s", b.Name, b.IdCard))
+					}
+					tutils.MultiSelectModal("Select Buyers", options, chosenBuyers, buyerLimit, mainPages, func(selected []int) {
+						mutex.Lock()
+						defer mutex.Unlock()
+						buyerChosenFunc(selected)
+					}, k)
+				}
+				addressSelectedFunc = func(text string, index int) {
+					address = addresses[index]
 				}
 				buyerCheckFunc = func() {
 					if buyerNameInput.GetText() != "" && buyerTelInput.GetText() != "" {
@@ -311,6 +660,39 @@ func main() {
 					mutex.Lock()
 					defer mutex.Unlock()
 					selectedTicket = tickets[index]
+					fallbackChosenFunc(nil)
+					fallbackBtn.SetDisabled(len(tickets) < 2)
+					maxPriceInput.SetText(tutils.FormatPrice(selectedTicket.Price))
+					maxPriceInput.SetDisabled(false)
+					address = api.AddressStruct{}
+					addressList.SetOptions([]string{"Not required"}, nil).SetCurrentOption(0)
+					addressList.SetDisabled(true)
+					if selectedTicket.NeedDelivery {
+						err, res := biliClient.GetAddressList()
+						if err != nil {
+							logger.Errorf("GetAddressList error: %v", err)
+							tutils.PopupModal(fmt.Sprintf("Bilibili API Returned An Unexpected Value,\n%s", err), mainPages, map[string]func() bool{
+								"OK": func() bool { return true },
+							}, k)
+							return
+						}
+						if len(res) == 0 {
+							addressList.SetOptions([]string{"No saved address, please add one in the app"}, nil).SetCurrentOption(0)
+						} else {
+							var addressOptions []string
+							selected := 0
+							for i, a := range res {
+								addressOptions = append(addressOptions, fmt.Sprintf("%s %s %s", a.Name, a.Phone, a.FullAddress()))
+								if a.Default == 1 {
+									selected = i
+								}
+							}
+							addresses = res
+							addressList.SetOptions(addressOptions, addressSelectedFunc)
+							addressList.SetDisabled(false)
+							addressList.SetCurrentOption(selected)
+						}
+					}
 					if buyerType == enums.ForceRealName {
 						err, res := biliClient.GetBuyerNoSensitiveInfo()
 						if err != nil {
@@ -320,13 +702,15 @@ func main() {
 							}, k)
 							return
 						}
-						var buyerOptions []string
-						for _, buyer := range res {
-							buyerOptions = append(buyerOptions, fmt.Sprintf("%s-%s", buyer.Name, buyer.IdCard))
-						}
 						buyers = res
-						buyerList.SetOptions(buyerOptions, buyerSelectedFunc)
-						buyerList.SetDisabled(false)
+						pid, _ := strconv.ParseInt(projID, 10, 64)
+						buyerLimit = ticket.BuyerLimit(biliClient, pid, selectedTicket.Limit)
+						// 换了票种后限购数可能变小, 重新选择
+						buyerChosenFunc(nil)
+						buyerBtn.SetDisabled(len(res) == 0)
+						if len(res) == 0 {
+							buyerLabel.SetText("No buyer found, please add one in the app")
+						}
 					} else if buyerType == enums.Ordinary {
 						buyerNameInput.SetDisabled(false)
 						buyerTelInput.SetDisabled(false)
@@ -336,22 +720,34 @@ func main() {
 					if projID == input.GetText() && projID != "" {
 						return
 					}
+					setEditingFunc("")
 					tickets = nil
 					selectedTicket = _return.TicketSkuScreenID{}
 					buyers = []api.BuyerNoSensitiveStruct{}
-					buyer = api.BuyerNoSensitiveStruct{}
+					chosenBuyers = nil
+					buyerLimit = 0
+					buyerLabel.SetText("")
+					fallbacks = nil
+					fallbackLabel.SetText("")
+					fallbackBtn.SetDisabled(true)
 					ticketList.SetDisabled(true)
-					buyerList.SetDisabled(true)
+					buyerBtn.SetDisabled(true)
 					addToQueueBtn.SetDisabled(true)
+					watchBtn.SetDisabled(true)
+					maxPriceInput.SetText("")
+					maxPriceInput.SetDisabled(true)
+					addresses = nil
+					address = api.AddressStruct{}
+					addressList.SetOptions([]string{"Nothing"}, nil).SetCurrentOption(0)
+					addressList.SetDisabled(true)
 					buyerNameInput.SetDisabled(true)
 					buyerTelInput.SetDisabled(true)
 					ticketList.SetOptions([]string{"Nothing"}, nil)
-					buyerList.SetOptions([]string{"Nothing"}, nil)
 					ticketList.SetCurrentOption(0)
-					buyerList.SetCurrentOption(0)
 					buyerFlexResetFunc()
+					projectDetail.Clear()
 				}
-				refreshTicketFunc = func() {
+				loadProjectFunc = func() {
 					mutex.Lock()
 					defer mutex.Unlock()
 					resetSelectionFunc()
@@ -359,6 +755,13 @@ func main() {
 						return
 					}
 					err, info := biliClient.GetProjectInformation(input.GetText())
+					if err != nil {
+						logger.Errorf("GetProjectInformation error: %v", err)
+						tutils.PopupModal(fmt.Sprintf("Bilibili API Returned An Unexpected Value,\n%s", err), mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
 					projName = info.ProjectName
 					buyerFlex.Clear()
 					if info.IsNeedContact {
@@ -369,27 +772,25 @@ func main() {
 					} else {
 						buyerType = enums.ForceRealName
 						buyerFlex.AddItem(tview.NewBox(), 1, 0, false)
-						buyerFlex.AddItem(buyerList, 1, 0, false)
+						buyerFlex.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+							AddItem(buyerBtn, 17, 0, false).
+							AddItem(tview.NewBox(), 2, 0, false).
+							AddItem(buyerLabel, 0, 1, false),
+							1, 0, false)
 					}
-					var i []_return.TicketSkuScreenID
+					projectDetail.SetText(tutils.FormatProjectDetail(info)).ScrollToBeginning()
 					if projID == input.GetText() && projID != "" {
 						return
 					}
 					projID = input.GetText()
-					err, i = biliClient.GetTicketSkuIDsByProjectID(input.GetText())
-					if err != nil {
-						logger.Errorf("GetTicketSkuIDsByProjectID error: %v", err)
-						tutils.PopupModal(fmt.Sprintf("Bilibili API Returned An Unexpected Value,\n%s", err), mainPages, map[string]func() bool{
-							"OK": func() bool { return true },
-						}, k)
-						return
-					}
+					watchBtn.SetDisabled(false)
+					i := info.Tickets()
 					var options []string
 					var validTickets []_return.TicketSkuScreenID
 					for _, t := range i {
 						if utils.IsTicketOnSale(t.Flags.Number) { //t.Flags.Number != 5 && t.Flags.Number != 3 && t.Flags.Number != 4 {
 							validTickets = append(validTickets, t)
-							options = append(options, fmt.Sprintf("%s-%s", t.Name, t.Desc))
+							options = append(options, fmt.Sprintf("%s-%s ¥%s", t.Name, t.Desc, tutils.FormatPrice(t.Price)))
 						}
 					}
 					tickets = validTickets
@@ -405,6 +806,57 @@ func main() {
 					ticketList.SetOptions(options, ticketSelectFunc)
 					ticketList.SetDisabled(false)
 				}
+				// refreshTicketFunc 输入可以是项目 ID 或分享链接, 链接中指定了票种时自动选中
+				refreshTicketFunc = func() {
+					text := strings.TrimSpace(input.GetText())
+					if text == "" {
+						return
+					}
+					err, link := biliClient.ResolveProjectLink(text)
+					if err != nil {
+						logger.Errorf("ResolveProjectLink error: %v", err)
+						tutils.PopupModal(fmt.Sprintf("Invalid project ID or link,\n%s", err), mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+					if id := strconv.FormatInt(link.ProjectID, 10); id != input.GetText() {
+						input.SetText(id)
+					}
+					loadProjectFunc()
+					if link.SkuID == 0 {
+						return
+					}
+					mutex.Lock()
+					index := -1
+					for i, t := range tickets {
+						if t.SkuID == link.SkuID && (link.ScreenID == 0 || t.ScreenID == link.ScreenID) {
+							index = i
+							break
+						}
+					}
+					mutex.Unlock()
+					if index >= 0 {
+						ticketList.SetCurrentOption(index)
+					}
+				}
+				// watchProjectFunc 关注整个项目, 已售罄的票种不会出现在下拉框中, 所以不按票种关注
+				watchProjectFunc = func() {
+					mutex.Lock()
+					pid, err := strconv.ParseInt(projID, 10, 64)
+					name := projName
+					mutex.Unlock()
+					if err != nil {
+						return
+					}
+					msg := fmt.Sprintf("Watching %s, checked every %s", name, ticketWatcher.Interval())
+					if !ticketWatcher.Add(pid, name, nil) {
+						msg = "This project is already being watched"
+					}
+					tutils.PopupModal(msg, mainPages, map[string]func() bool{
+						"OK": func() bool { return true },
+					}, k)
+				}
 				addToWaitingQueue = func() {
 					mutex.Lock()
 					defer mutex.Unlock()
@@ -412,15 +864,53 @@ func main() {
 					if err != nil {
 						return
 					}
+					maxPrice, err := tutils.ParsePrice(maxPriceInput.GetText())
+					if err != nil || maxPrice < selectedTicket.Price {
+						tutils.PopupModal(fmt.Sprintf("Max price must be at least the ticket price (%s)", tutils.FormatPrice(selectedTicket.Price)), mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+					var alternatives []models.TicketAlternative
+					for _, i := range fallbacks {
+						t := tickets[i]
+						if t.Price > maxPrice {
+							tutils.PopupModal(fmt.Sprintf("Fallback %s-%s costs more than the max price", t.Name, t.Desc), mainPages, map[string]func() bool{
+								"OK": func() bool { return true },
+							}, k)
+							return
+						}
+						if t.NeedDelivery && address.Id == 0 {
+							tutils.PopupModal(fmt.Sprintf("Fallback %s-%s ships by express, please select the ticket first to choose an address", t.Name, t.Desc), mainPages, map[string]func() bool{
+								"OK": func() bool { return true },
+							}, k)
+							return
+						}
+						alternatives = append(alternatives, models.TicketAlternative{
+							ScreenID:   t.ScreenID,
+							ScreenName: t.Name,
+							SkuID:      t.SkuID,
+							SkuName:    t.Desc,
+						})
+					}
+					if selectedTicket.NeedDelivery && address.Id == 0 {
+						tutils.PopupModal("Please select a delivery address", mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
 					entry := models.TicketEntry{
-						ProjectID:   pid,
-						ProjectName: projName,
-						Expire:      selectedTicket.SaleStat.End.Unix(),
-						Start:       selectedTicket.SaleStat.Start.Unix(),
-						SkuID:       selectedTicket.SkuID,
-						SkuName:     selectedTicket.Desc,
-						ScreenID:    selectedTicket.ScreenID,
-						ScreenName:  selectedTicket.Name,
+						MaxPrice:     maxPrice,
+						AddressID:    address.Id,
+						ProjectID:    pid,
+						ProjectName:  projName,
+						Expire:       selectedTicket.SaleStat.End.Unix(),
+						Start:        selectedTicket.SaleStat.Start.Unix(),
+						SkuID:        selectedTicket.SkuID,
+						SkuName:      selectedTicket.Desc,
+						ScreenID:     selectedTicket.ScreenID,
+						ScreenName:   selectedTicket.Name,
+						Alternatives: alternatives,
 					}
 					if buyerType == enums.Ordinary {
 						if buyerNameInput.GetText() == "" || buyerTelInput.GetText() == "" {
@@ -435,19 +925,46 @@ func main() {
 							Name:      buyerNameInput.GetText(),
 						}
 					} else {
-						if buyer.Id == 0 {
+						if len(chosenBuyers) == 0 {
 							tutils.PopupModal("Please select a buyer", mainPages, map[string]func() bool{
 								"OK": func() bool { return true },
 							}, k)
 							return
 						}
-						entry.Buyer = _return.TicketBuyer{
-							BuyerType: enums.ForceRealName,
-							ID:        buyer.Id,
-							Name:      buyer.Name,
+						if buyerLimit > 0 && len(chosenBuyers) > buyerLimit {
+							tutils.PopupModal(fmt.Sprintf("At most %d buyers for this ticket", buyerLimit), mainPages, map[string]func() bool{
+								"OK": func() bool { return true },
+							}, k)
+							return
+						}
+						for _, i := range chosenBuyers {
+							entry.Buyers = append(entry.Buyers, _return.TicketBuyer{
+								BuyerType: enums.ForceRealName,
+								ID:        buyers[i].Id,
+								Name:      buyers[i].Name,
+							})
+						}
+						entry.Buyer = entry.Buyers[0]
+					}
+					if editingID != "" {
+						if err := ticketManager.Update(editingID, entry); err != nil {
+							tutils.PopupModal(err.Error(), mainPages, map[string]func() bool{
+								"OK": func() bool { return true },
+							}, k)
+							return
 						}
+						setEditingFunc("")
+						tutils.PopupModal("Task updated Successfully", mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+					if err := data.AddTicket(entry); err != nil {
+						tutils.PopupModal(err.Error(), mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
 					}
-					data.AddTicket(entry)
 					tutils.PopupModal("Add to queue Successfully", mainPages, map[string]func() bool{
 						"OK": func() bool { return true },
 					}, k)
@@ -456,25 +973,30 @@ func main() {
 			{
 				buyerTelInput.SetLabel("Tel: ").SetFieldWidth(20).SetPlaceholder("Enter Tel")
 				buyerNameInput.SetLabel("Name: ").SetFieldWidth(20).SetPlaceholder("Enter Name")
+				maxPriceInput.SetLabel("Max Price: ").SetFieldWidth(12).SetPlaceholder("CNY")
+				maxPriceInput.SetAcceptanceFunc(tview.InputFieldFloat)
+				addressList.SetLabel("Delivery Address: ").SetOptions([]string{"Nothing"}, nil).SetCurrentOption(0)
+				addressList.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor)).SetDisabled(true)
 				ticketList.SetLabel("Select Ticket: ").SetOptions([]string{"Nothing"}, nil).SetCurrentOption(0)
-				buyerList.SetLabel("Select Buyer: ").SetOptions([]string{"Nothing"}, nil).SetCurrentOption(0)
+				buyerBtn = tview.NewButton(" Select Buyers ").SetSelectedFunc(chooseBuyersFunc)
+				fallbackBtn = tview.NewButton(" Fallbacks ").SetSelectedFunc(chooseFallbacksFunc)
+				fallbackBtn.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor))
+				fallbackBtn.SetDisabled(true)
+				buyerBtn.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor))
 				ticketList.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor)).SetDisabled(true)
-				buyerList.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor)).SetDisabled(true)
+				buyerBtn.SetDisabled(true)
 				buyerNameInput.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor)).SetDisabled(true)
 				buyerTelInput.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor)).SetDisabled(true)
+				maxPriceInput.SetDisabledStyle(tcell.StyleDefault.Background(tcell.ColorLightBlue).Foreground(tview.Styles.ContrastSecondaryTextColor)).SetDisabled(true)
 				buyerFlexResetFunc()
 				buyerNameInput.SetChangedFunc(func(text string) { buyerCheckFunc() })
 				buyerTelInput.SetChangedFunc(func(text string) { buyerCheckFunc() })
 			}
 			root := tview.NewFlex().SetDirection(tview.FlexRow)
 			input = tview.NewInputField().
-				SetAcceptanceFunc(func(text string, ch rune) bool {
-					_, err := strconv.Atoi(text)
-					return err == nil
-				}).
-				SetLabel("Project ID: ").
-				SetFieldWidth(20).
-				SetPlaceholder("Enter Project ID").
+				SetLabel("Project: ").
+				SetFieldWidth(40).
+				SetPlaceholder("Enter Project ID or share link").
 				SetChangedFunc(func(text string) {
 					mutex.Lock()
 					defer mutex.Unlock()
@@ -483,27 +1005,382 @@ func main() {
 			input.SetDoneFunc(func(key tcell.Key) { refreshTicketFunc() })
 			root.AddItem(tview.NewFlex().
 				SetDirection(tview.FlexColumn).
-				AddItem(input, 32, 1, false).
+				AddItem(input, 49, 1, false).
 				AddItem(tview.NewBox(), 2, 0, false).
 				AddItem(tview.NewButton("OK").SetSelectedFunc(refreshTicketFunc), 4, 0, false),
 				1, 0, false)
 			root.AddItem(tview.NewBox(), 1, 0, false)
 			root.AddItem(ticketList, 1, 0, false)
 			root.AddItem(tview.NewBox(), 1, 0, false)
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(fallbackBtn, 13, 0, false).
+				AddItem(tview.NewBox(), 2, 0, false).
+				AddItem(fallbackLabel, 0, 1, false),
+				1, 0, false)
+			root.AddItem(tview.NewBox(), 1, 0, false)
+			root.AddItem(maxPriceInput, 1, 0, false)
+			root.AddItem(tview.NewBox(), 1, 0, false)
+			root.AddItem(addressList, 1, 0, false)
+			root.AddItem(tview.NewBox(), 1, 0, false)
 			root.AddItem(buyerFlex, 3, 0, false)
 			root.AddItem(tview.NewBox(), 1, 0, false)
 			addToQueueBtn = tview.NewButton(" Add to Automatic Ticket Booking Queue ").SetSelectedFunc(addToWaitingQueue)
 			addToQueueBtn.SetDisabled(true)
-			root.AddItem(addToQueueBtn, 1, 0, false)
+			watchBtn = tview.NewButton(" Watch Availability ").SetSelectedFunc(watchProjectFunc)
+			watchBtn.SetDisabled(true)
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(addToQueueBtn, 0, 2, false).
+				AddItem(tview.NewBox(), 2, 0, false).
+				AddItem(watchBtn, 0, 1, false),
+				1, 0, false)
+			root.AddItem(tview.NewBox(), 1, 0, false)
+			projectDetail.SetBorder(true).SetTitle("PROJECT DETAIL")
+			root.AddItem(projectDetail, 0, 1, false)
 			functionPages.AddPage("ticket", root, true, false)
+			openProject = func(projectID string) {
+				input.SetText(projectID)
+				functionPages.SetTitle("TICKET")
+				functionPages.SwitchToPage("ticket")
+				refreshTicketFunc()
+			}
+			// editTicket 载入任务的内容, 保存时替换原任务, 任务 ID 和日志不变
+			editTicket = func(t models.TicketEntry) {
+				openProject(strconv.FormatInt(t.ProjectID, 10))
+				mutex.Lock()
+				index := slices.IndexFunc(tickets, func(s _return.TicketSkuScreenID) bool {
+					return s.SkuID == t.SkuID && s.ScreenID == t.ScreenID
+				})
+				mutex.Unlock()
+				if index >= 0 {
+					ticketList.SetCurrentOption(index)
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				setEditingFunc(t.ID)
+				if index < 0 {
+					tutils.PopupModal("The ticket of this task is not on sale anymore, please choose another one", mainPages, map[string]func() bool{
+						"OK": func() bool { return true },
+					}, k)
+					return
+				}
+				if t.MaxPrice > 0 {
+					maxPriceInput.SetText(tutils.FormatPrice(t.MaxPrice))
+				}
+				if t.Buyer.BuyerType == enums.Ordinary {
+					buyerNameInput.SetText(t.Buyer.Name)
+					buyerTelInput.SetText(t.Buyer.Tel)
+				} else {
+					var selected []int
+					for _, b := range t.AllBuyers() {
+						if i := slices.IndexFunc(buyers, func(o api.BuyerNoSensitiveStruct) bool { return o.Id == b.ID }); i >= 0 {
+							selected = append(selected, i)
+						}
+					}
+					buyerChosenFunc(selected)
+				}
+				var selected []int
+				for _, a := range t.Alternatives {
+					i := slices.IndexFunc(tickets, func(s _return.TicketSkuScreenID) bool {
+						return s.SkuID == a.SkuID && s.ScreenID == a.ScreenID
+					})
+					if i >= 0 && i != index {
+						selected = append(selected, i)
+					}
+				}
+				fallbackChosenFunc(selected)
+				if i := slices.IndexFunc(addresses, func(a api.AddressStruct) bool { return a.Id == t.AddressID }); i >= 0 && selectedTicket.NeedDelivery {
+					addressList.SetCurrentOption(i)
+				}
+			}
 		}
 		{
 			var (
-				current = -1
-				hash    []string
+				mutex    sync.Mutex
+				page     = 1
+				numPages = 1
+				projects []_return.ProjectSummary
+			)
+			root := tview.NewFlex().SetDirection(tview.FlexRow)
+			keywordInput := tview.NewInputField().SetLabel("Keyword: ").SetFieldWidth(20).SetPlaceholder("All projects")
+			cityInput := tview.NewInputField().SetLabel("City: ").SetFieldWidth(8)
+			fromInput := tview.NewInputField().SetLabel("From: ").SetFieldWidth(10).SetPlaceholder("2006-01-02")
+			toInput := tview.NewInputField().SetLabel("To: ").SetFieldWidth(10).SetPlaceholder("2006-01-02")
+			table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+			pageLabel := tview.NewTextView().SetTextAlign(tview.AlignCenter)
+			showResults := func(result *_return.ProjectSearchResult, city string, from, to time.Time) {
+				table.Clear()
+				for i, h := range []string{"ID", "Name", "City", "Venue", "Dates", "Price", "Status"} {
+					table.SetCell(0, i, tview.NewTableCell(h).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+				}
+				projects = projects[:0]
+				for _, p := range result.Projects {
+					if !p.Match(city, from, to) {
+						continue
+					}
+					projects = append(projects, p)
+					row := len(projects)
+					dates := p.Start.Format("2006-01-02")
+					if !p.End.IsZero() && p.End.Format("2006-01-02") != dates {
+						dates += " ~ " + p.End.Format("01-02")
+					}
+					if p.Start.IsZero() {
+						dates = "-"
+					}
+					price := tutils.FormatPrice(p.PriceLow)
+					if p.PriceHigh > p.PriceLow {
+						price += "-" + tutils.FormatPrice(p.PriceHigh)
+					}
+					table.SetCell(row, 0, tview.NewTableCell(strconv.FormatInt(p.ProjectID, 10)))
+					table.SetCell(row, 1, tview.NewTableCell(p.Name).SetMaxWidth(40).SetExpansion(1))
+					table.SetCell(row, 2, tview.NewTableCell(p.City))
+					table.SetCell(row, 3, tview.NewTableCell(p.Venue).SetMaxWidth(20))
+					table.SetCell(row, 4, tview.NewTableCell(dates))
+					table.SetCell(row, 5, tview.NewTableCell(price))
+					table.SetCell(row, 6, tview.NewTableCell(p.SaleFlag))
+				}
+				numPages = max(result.NumPages, 1)
+				pageLabel.SetText(fmt.Sprintf("Page %d/%d, %d shown of %d", page, numPages, len(projects), result.Total))
+				table.ScrollToBeginning()
+			}
+			searchFunc := func(target int) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				var from, to time.Time
+				var err error
+				if text := fromInput.GetText(); text != "" {
+					if from, err = time.ParseInLocation("2006-01-02", text, time.Local); err != nil {
+						tutils.PopupModal("Invalid date: "+text, mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+				}
+				if text := toInput.GetText(); text != "" {
+					if to, err = time.ParseInLocation("2006-01-02", text, time.Local); err != nil {
+						tutils.PopupModal("Invalid date: "+text, mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+					to = to.Add(24*time.Hour - time.Second)
+				}
+				err, result := biliClient.SearchProjects(strings.TrimSpace(keywordInput.GetText()), target)
+				if err != nil {
+					logger.Errorf("SearchProjects error: %v", err)
+					tutils.PopupModal(fmt.Sprintf("Bilibili API Returned An Unexpected Value,\n%s", err), mainPages, map[string]func() bool{
+						"OK": func() bool { return true },
+					}, k)
+					return
+				}
+				page = target
+				showResults(result, strings.TrimSpace(cityInput.GetText()), from, to)
+			}
+			table.SetSelectedFunc(func(row, _ int) {
+				if row < 1 || row > len(projects) {
+					return
+				}
+				openProject(strconv.FormatInt(projects[row-1].ProjectID, 10))
+			})
+			for _, field := range []*tview.InputField{keywordInput, cityInput, fromInput, toInput} {
+				field.SetDoneFunc(func(key tcell.Key) {
+					if key == tcell.KeyEnter {
+						searchFunc(1)
+					}
+				})
+			}
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(keywordInput, 30, 0, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(cityInput, 15, 0, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(fromInput, 17, 0, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(toInput, 15, 0, false).
+				AddItem(tview.NewBox(), 1, 0, false).
+				AddItem(tview.NewButton("Search").SetSelectedFunc(func() { searchFunc(1) }), 8, 0, false),
+				1, 0, false)
+			root.AddItem(tview.NewBox(), 1, 0, false)
+			root.AddItem(table, 0, 1, false)
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(tview.NewButton("Prev").SetSelectedFunc(func() {
+					if page > 1 {
+						searchFunc(page - 1)
+					}
+				}), 6, 0, false).
+				AddItem(pageLabel, 0, 1, false).
+				AddItem(tview.NewButton("Next").SetSelectedFunc(func() {
+					if page < numPages {
+						searchFunc(page + 1)
+					}
+				}), 6, 0, false),
+				1, 0, false)
+			functionPages.AddPage("search", root, true, false)
+		}
+		{
+			root := tview.NewFlex().SetDirection(tview.FlexRow)
+			list := tview.NewList()
+			var watches []models.WatchEntry
+			refreshWatches := func() {
+				current := list.GetCurrentItem()
+				list.Clear()
+				watches = data.GetWatches()
+				for _, w := range watches {
+					onSale := 0
+					for _, sku := range w.Known {
+						if utils.IsTicketOnSale(sku.Flag) {
+							onSale++
+						}
+					}
+					status := "Waiting for the first check"
+					if w.LastCheck > 0 {
+						status = fmt.Sprintf("%d/%d SKUs on sale, checked at %s", onSale, len(w.Known), time.Unix(w.LastCheck, 0).Format("01-02 15:04:05"))
+					}
+					list.AddItem(fmt.Sprintf("%s (%d)", tview.Escape(w.ProjectName), w.ProjectID), status, 0, nil)
+				}
+				if current < list.GetItemCount() {
+					list.SetCurrentItem(current)
+				}
+			}
+			list.SetSelectedFunc(func(i int, _ string, _ string, _ rune) {
+				if i >= len(watches) {
+					return
+				}
+				w := watches[i]
+				tutils.PopupModal(fmt.Sprintf("Stop watching %s?", w.ProjectName), mainPages, map[string]func() bool{
+					"Yes": func() bool {
+						ticketWatcher.Remove(w.ProjectID)
+						return true
+					},
+					"No": func() bool { return true },
+				}, k)
+			})
+			ticketWatcher.SetChangeFunc(func() {
+				go app.QueueUpdateDraw(refreshWatches)
+			})
+			refreshWatches()
+			root.AddItem(list, 0, 1, false)
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(tview.NewTextView().SetText(fmt.Sprintf("Checked every %s. Select a project to stop watching it.", ticketWatcher.Interval())), 0, 1, false).
+				AddItem(tview.NewButton("Check Now").SetSelectedFunc(ticketWatcher.CheckNow), 11, 0, false),
+				1, 0, false)
+			functionPages.AddPage("watch", root, true, false)
+		}
+		{
+			var (
+				mutex  sync.Mutex
+				buyers []api.BuyerNoSensitiveStruct
+			)
+			root := tview.NewFlex().SetDirection(tview.FlexRow)
+			table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+			issuesView := tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+			refreshBuyers := func() {
+				mutex.Lock()
+				defer mutex.Unlock()
+				err, res := biliClient.GetBuyerNoSensitiveInfo()
+				if err != nil {
+					logger.Errorf("GetBuyerNoSensitiveInfo error: %v", err)
+					tutils.PopupModal(fmt.Sprintf("Bilibili API Returned An Unexpected Value,\n%s", err), mainPages, map[string]func() bool{
+						"OK": func() bool { return true },
+					}, k)
+					return
+				}
+				buyers = res
+				table.Clear()
+				for i, h := range []string{"ID", "Name", "ID Type", "ID Number", "Tel", "Verified", "Status"} {
+					table.SetCell(0, i, tview.NewTableCell(h).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+				}
+				for i, b := range buyers {
+					verified := tview.NewTableCell("Yes").SetTextColor(tcell.ColorGreen)
+					if !b.Verified() {
+						verified = tview.NewTableCell(fmt.Sprintf("No (%d)", b.VerifyStatus)).SetTextColor(tcell.ColorRed)
+					}
+					table.SetCell(i+1, 0, tview.NewTableCell(strconv.FormatInt(b.Id, 10)))
+					table.SetCell(i+1, 1, tview.NewTableCell(b.Name).SetExpansion(1))
+					table.SetCell(i+1, 2, tview.NewTableCell(b.IdName))
+					table.SetCell(i+1, 3, tview.NewTableCell(utils.MaskIDCard(b.IdCard)))
+					table.SetCell(i+1, 4, tview.NewTableCell(utils.MaskPhone(b.Tel)))
+					table.SetCell(i+1, 5, verified)
+					table.SetCell(i+1, 6, tview.NewTableCell(strconv.Itoa(b.Status)))
+				}
+				issues := ticketManager.CheckBuyers(buyers)
+				if len(issues) == 0 {
+					issuesView.SetText("[green]All queued tickets have valid buyers")
+					return
+				}
+				lines := make([]string, 0, len(issues))
+				for _, issue := range issues {
+					lines = append(lines, fmt.Sprintf("[red]%s[white] (%s)", tview.Escape(issue.String()), issue.ID[0:9]))
+				}
+				issuesView.SetText(strings.Join(lines, "\n"))
+			}
+			addBuyerFunc := func() {
+				tutils.FormModal("Add Buyer", []string{"Name", "ID Card Number", "Tel"}, mainPages, func(values []string) {
+					name, idCard, tel := strings.TrimSpace(values[0]), strings.TrimSpace(values[1]), strings.TrimSpace(values[2])
+					if name == "" || idCard == "" {
+						tutils.PopupModal("Name and ID card number are required", mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+					if err := biliClient.AddBuyer(name, api.IdTypeIDCard, idCard, tel); err != nil {
+						logger.Errorf("AddBuyer error: %v", err)
+						tutils.PopupModal(fmt.Sprintf("Failed to add buyer,\n%s", err), mainPages, map[string]func() bool{
+							"OK": func() bool { return true },
+						}, k)
+						return
+					}
+					logger.Infof("Buyer %s added", name)
+					refreshBuyers()
+				}, k)
+			}
+			deleteBuyerFunc := func() {
+				mutex.Lock()
+				row, _ := table.GetSelection()
+				if row < 1 || row > len(buyers) {
+					mutex.Unlock()
+					return
+				}
+				b := buyers[row-1]
+				mutex.Unlock()
+				tutils.PopupModal(fmt.Sprintf("Delete buyer %s (%s)?\nQueued tickets using this buyer will fail.", b.Name, utils.MaskIDCard(b.IdCard)), mainPages, map[string]func() bool{
+					"Yes": func() bool {
+						if err := biliClient.DeleteBuyer(b.Id); err != nil {
+							logger.Errorf("DeleteBuyer error: %v", err)
+							return true
+						}
+						logger.Infof("Buyer %s deleted", b.Name)
+						refreshBuyers()
+						return true
+					},
+					"No": func() bool { return true },
+				}, k)
+			}
+			root.AddItem(table, 0, 2, false)
+			root.AddItem(tview.NewTextView().SetText("Queued ticket problems:"), 1, 0, false)
+			root.AddItem(issuesView, 0, 1, false)
+			root.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(tview.NewButton("Refresh").SetSelectedFunc(refreshBuyers), 9, 0, false).
+				AddItem(tview.NewBox(), 2, 0, false).
+				AddItem(tview.NewButton("Add").SetSelectedFunc(addBuyerFunc), 5, 0, false).
+				AddItem(tview.NewBox(), 2, 0, false).
+				AddItem(tview.NewButton("Delete").SetSelectedFunc(deleteBuyerFunc), 8, 0, false).
+				AddItem(tview.NewBox(), 0, 1, false),
+				1, 0, false)
+			openBuyers = refreshBuyers
+			functionPages.AddPage("buyers", root, true, false)
+		}
+		{
+			var (
+				current string // 详情页显示的任务 ID
+				ids     []string
 			)
 			root := primitives.NewPages()
 			root.SetBorder(true).SetTitle("TICKET LIST")
+			popupError := func(err error) {
+				tutils.PopupModal(err.Error(), mainPages, map[string]func() bool{
+					"OK": func() bool { return true },
+				}, k)
+			}
 			list := tview.NewList()
 			logs := tview.NewTextView().SetMaxLines(200).SetDynamicColors(true).SetChangedFunc(func() {
 				if app != nil {
@@ -512,8 +1389,12 @@ func main() {
 			})
 			logFlex := tview.NewFlex().AddItem(logs, 0, 1, true).SetDirection(tview.FlexRow)
 			logFlex.SetBorder(true)
+			statsView := tview.NewTextView().SetDynamicColors(true)
+			statsView.SetBorder(true).SetTitle("STATS")
 			detail := tview.NewFlex().SetDirection(tview.FlexRow).
-				AddItem(logFlex, 0, 1, false).
+				AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+					AddItem(logFlex, 0, 3, false).
+					AddItem(statsView, 36, 0, false), 0, 1, false).
 				AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
 					AddItem(tview.NewBox(), 2, 0, false).
 					AddItem(tview.NewButton("Exit").SetSelectedFunc(func() {
@@ -526,11 +1407,10 @@ func main() {
 							"Yes": func() bool {
 								root.SwitchToPage("list")
 								root.SetTitle("TICKET LIST")
-								if current != -1 {
-									if ticketRoutineInfo[hash[current]].routine.IsRunning() {
-										ticketRoutineInfo[hash[current]].routine.Stop()
+								if current != "" {
+									if err := ticketManager.Remove(current); err != nil {
+										logger.Errorf("Failed to remove ticket: %v", err)
 									}
-									data.RemoveTicket(int64(current))
 								}
 								return true
 							},
@@ -539,67 +1419,123 @@ func main() {
 					}), 0, 1, false).
 					AddItem(tview.NewTextView().SetDynamicColors(true).SetMaxLines(200), 2, 0, false).
 					AddItem(tview.NewButton("Force Start").SetSelectedFunc(func() {
-						successTicketTask[hash[current]] = false
-						if ticketRoutineInfo[hash[current]].routine.IsRunning() {
-							ticketRoutineInfo[hash[current]].routine.Stop()
+						if err := ticketManager.Start(current); err != nil {
+							popupError(err)
+						}
+					}), 0, 1, false).
+					AddItem(tview.NewBox(), 2, 0, false).
+					AddItem(tview.NewButton("Pause/Resume").SetSelectedFunc(func() {
+						e, ok := ticketManager.Get(current)
+						if !ok {
+							return
+						}
+						var err error
+						if e.Routine.State() == enums.StatePaused {
+							err = ticketManager.Resume(current)
+						} else {
+							err = ticketManager.Pause(current)
+						}
+						if err != nil {
+							popupError(err)
+						}
+					}), 0, 1, false).
+					AddItem(tview.NewBox(), 2, 0, false).
+					AddItem(tview.NewButton("Edit").SetSelectedFunc(func() {
+						e, ok := ticketManager.Get(current)
+						if !ok {
+							return
+						}
+						if state := e.Routine.State(); state == enums.StateRunning || state == enums.StateSucceeded {
+							popupError(fmt.Errorf("can not edit a %s task, pause it first", strings.ToLower(state.String())))
+							return
+						}
+						editTicket(e.Ticket)
+					}), 0, 1, false).
+					AddItem(tview.NewBox(), 2, 0, false).
+					AddItem(tview.NewButton("Move Up").SetSelectedFunc(func() {
+						if err := ticketManager.Move(current, -1); err != nil {
+							popupError(err)
+						}
+					}), 0, 1, false).
+					AddItem(tview.NewBox(), 2, 0, false).
+					AddItem(tview.NewButton("Move Down").SetSelectedFunc(func() {
+						if err := ticketManager.Move(current, 1); err != nil {
+							popupError(err)
 						}
-						ticketRoutineInfo[hash[current]].routine.Start()
 					}), 0, 1, false).
 					AddItem(tview.NewBox(), 2, 0, false), 1, 0, false)
 			ANSI := tview.ANSIWriter(logs)
-			notify := func(storage *models.DataStorage, t models.TicketEntry) {
+			refreshList := func() {
 				list.Clear()
-				hash = []string{}
-				logger.Debugf("t: %+v", t)
-				for i, t := range storage.GetTickets() {
-					h := t.Hash()
-					if _, exists := ticketRoutineInfo[h]; exists {
-						continue
+				ids = []string{}
+				for i, e := range ticketManager.Entries() {
+					t := e.Ticket
+					ids = append(ids, e.ID)
+					secondary := fmt.Sprintf(" [%s]{%s}(%s) %s", t.ScreenName, t.BuyerNames(), e.ID[0:9], e.Routine.State())
+					if len(t.Alternatives) > 0 {
+						secondary += fmt.Sprintf(" +%d fallbacks", len(t.Alternatives))
 					}
-					cache := hooks.NewLoggerCache(200, nil)
-					handler := hooks.NewRoutineHandlerHook(func(i int, fields logrus.Fields) {
-						if i == enums.Success || i == enums.Failed || i == enums.Error {
-							schedulerManager.RemoveTask(h)
-							successTicketTask[h] = true
+					if res := e.Routine.Result(); res.State == enums.StateSucceeded {
+						if c := t.ChoiceIndex(res.ScreenID, res.SkuID); c > 0 {
+							secondary += fmt.Sprintf(", got fallback %d: %s", c, t.Choices()[c])
 						}
-					})
-					loghooks := []logrus.Hook{cache, handler}
-					err, routine := ticket.NewTicketRoutine(biliClient, t, loghooks, notifyManager)
-					if err != nil {
-						logger.Errorf("Failed to create ticket routine[hash:%s]: %v", h[:11], err)
-						continue
 					}
-					ticketRoutineInfo[h] = &ticketRoutineInformation{
-						routine:  routine,
-						logCache: cache,
-					}
-					schedulerManager.AddTask(h, time.Unix(t.Expire, 0), func() {
-						if !ticketRoutineInfo[hash[current]].routine.IsRunning() {
-							ticketRoutineInfo[hash[current]].routine.Start()
-						}
-					})
-					hash = append(hash, h)
-					list.AddItem(fmt.Sprintf("%d.%s(%s)", i+1, t.ProjectName, t.SkuName), fmt.Sprintf(" [%s]{%s}(%s)", t.ScreenName, t.Buyer.Name, h[0:9]), 0, nil)
+					list.AddItem(fmt.Sprintf("%d.%s(%s)", i+1, t.ProjectName, t.SkuName), secondary, 0, nil)
 				}
-				logger.Debugf("storage: %+v", storage)
 			}
-			data.SetTicketChangeNotifyFunc(&notify)
-			notify(data, models.TicketEntry{})
+			ticketManager.Subscribe(func(event ticket.Event) {
+				go app.QueueUpdateDraw(refreshList)
+			})
+			data.Subscribe(func(change models.TicketChange) {
+				logger.Debugf("Ticket %s[id:%s]", change.Kind, change.Ticket.ID[:11])
+				ticketManager.Sync()
+				go app.QueueUpdateDraw(refreshList)
+			})
+			ticketManager.Sync()
+			refreshList()
 			functionPages.AddPage("status",
 				root,
 				true,
 				false)
+			refreshStats := func() {
+				if current == "" {
+					return
+				}
+				e, exists := ticketManager.Get(current)
+				if !exists {
+					return
+				}
+				statsView.SetText(fmt.Sprintf("State: %s\n\n%s", e.Routine.State(), tutils.FormatTicketStats(e.Routine.Stats())))
+			}
+			go func() {
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+				for range ticker.C {
+					app.QueueUpdateDraw(func() {
+						if name, _ := root.GetFrontPage(); name == "detail" {
+							refreshStats()
+						}
+					})
+				}
+			}()
 			list.SetSelectedFunc(func(i int, _ string, _ string, _ rune) {
-				if current != -1 {
-					ticketRoutineInfo[hash[current]].logCache.SetOutput(nil)
+				if current != "" {
+					if e, ok := ticketManager.Get(current); ok {
+						e.LogCache.SetOutput(nil)
+					}
 				}
-				current = i
+				current = ids[i]
+				refreshStats()
 				logs.Clear()
-				for _, s := range ticketRoutineInfo[hash[current]].logCache.GetEntries() {
+				e, ok := ticketManager.Get(current)
+				if !ok {
+					return
+				}
+				for _, s := range e.LogCache.GetEntries() {
 					ANSI.Write([]byte(s))
 				}
 				logs.ScrollToEnd()
-				ticketRoutineInfo[hash[current]].logCache.SetOutput(ANSI)
+				e.LogCache.SetOutput(ANSI)
 				root.SwitchToPage("detail")
 				root.SetTitle("DETAIL")
 			})
@@ -626,6 +1562,14 @@ func main() {
 			form.AddButton("Save", nil).
 				AddButton("Reset", func() {
 					app.Stop()
+				}).
+				AddButton("Unredact Logs 10m", func() {
+					utils.DisableRedaction(unredactPeriod)
+					logger.Warnf("Log redaction is disabled for %s, logs may contain credentials and personal information.", unredactPeriod)
+				}).
+				AddButton("Redact Logs", func() {
+					utils.EnableRedaction()
+					logger.Info("Log redaction is enabled.")
 				})
 			root.AddItem(form, 0, 1, true)
 			functionPages.AddPage("setting",
@@ -641,6 +1585,9 @@ func main() {
 			list.AddItem("Bilibili Client", "Account Info/Login", 'l', func() {})
 			list.AddItem("Logs", "Latest Logs", 'o', func() {})
 			list.AddItem("Ticket", "Ticket Booking", 't', func() {})
+			list.AddItem("Search", "Browse Projects", 'f', func() {})
+			list.AddItem("Watch", "Availability Watch", 'w', func() {})
+			list.AddItem("Buyers", "Buyer Management", 'b', func() {})
 			list.AddItem("Status", "Booking Status", 's', func() {})
 			list.AddItem("Settings", "Configure", 'c', func() {})
 			list.SetSelectedFunc(func(i int, mt string, _ string, _ rune) {
@@ -650,11 +1597,19 @@ func main() {
 					functionPages.SwitchToPage("client")
 				case 1:
 					functionPages.SwitchToPage("logs")
+					openLogs()
 				case 2:
 					functionPages.SwitchToPage("ticket")
 				case 3:
-					functionPages.SwitchToPage("status")
+					functionPages.SwitchToPage("search")
 				case 4:
+					functionPages.SwitchToPage("watch")
+				case 5:
+					functionPages.SwitchToPage("buyers")
+					openBuyers()
+				case 6:
+					functionPages.SwitchToPage("status")
+				case 7:
 					functionPages.SwitchToPage("setting")
 				}
 			})
@@ -662,7 +1617,26 @@ func main() {
 		}
 	}
 	mainPages.AddPage("main", flex, true, true)
-	app.SetInputCapture(k.InputCapture)
+	if *daemonMode {
+		server := daemon.NewServer(app, *socketPath)
+		if err := server.Start(); err != nil {
+			logger.Errorf("Failed to start daemon: %v", err)
+			fmt.Fprintf(os.Stderr, "Failed to start daemon: %v\n", err)
+			return 1
+		}
+		defer server.Close()
+		app.SetScreen(daemon.DetachedScreen())
+		// Ctrl-C 只断开当前客户端, 任务继续运行
+		app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyCtrlC {
+				go server.Detach()
+				return nil
+			}
+			return k.InputCapture(event)
+		})
+	} else {
+		app.SetInputCapture(k.InputCapture)
+	}
 	app.SetMouseCapture(func(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
 		if k.Selected() && (action == tview.MouseRightClick || action == tview.MouseMiddleClick || action == tview.MouseLeftClick) {
 			k.Reset()
@@ -675,21 +1649,30 @@ func main() {
 		logger.Info("Under the AGPLv3 License.")
 		logger.Infof("Commit hash: %s", global.GitCommit)
 		logger.Infof("Build timestamp: %s", global.BuildTime)
-		err, r := biliClient.GetLoginStatus()
+	}()
+	if conf.Control.Enable {
+		server, err := control.NewServer(conf.Control, ticketManager, data, biliClient, schedulerManager)
 		if err != nil {
-			logger.Errorf("Something went wrong when get logging status, %v", err)
+			logger.Errorf("Failed to create control server: %v", err)
+		} else if err = server.Start(); err != nil {
+			logger.Errorf("Failed to start control server: %v", err)
+		} else {
+			servers = append(servers, server)
 		}
-		if r.Login {
-			err, b := biliClient.TryToRefreshNewBiliTicket()
-			if err != nil {
-				logger.Errorf("Something went wrong when refreshing bili-ticket, %v", err)
-			} else if !b {
-				logger.Info("No need to refresh bili-ticket, it is still valid.")
-			} else {
-				logger.Info("Bili-ticket refreshed successfully.")
-			}
+	}
+	if conf.Metrics.Enable {
+		registerMetrics()
+		server := metrics.NewServer(conf.Metrics.Address)
+		if err := server.Start(); err != nil {
+			logger.Errorf("Failed to start metrics server: %v", err)
+		} else {
+			servers = append(servers, server)
 		}
-	}()
+	}
+	ticketWatcher.Start()
+	defer ticketWatcher.Stop()
+	sessionMonitor.Start()
+	defer sessionMonitor.Stop()
 	//offest sync
 	go func() {
 		for {
@@ -701,7 +1684,44 @@ func main() {
 			time.Sleep(60 * time.Second) //setting
 		}
 	}()
+	if *projectLink != "" {
+		go app.QueueUpdateDraw(func() { openProject(*projectLink) })
+	}
+	handleSignals()
 	if err := app.SetRoot(mainPages, true).Run(); err != nil {
-		logger.Fatal(err)
+		logger.Error(err)
+		return 1
 	}
+	return 0
+}
+
+// registerMetrics 注册抓取时才计算的指标
+func registerMetrics() {
+	metrics.NewGaugeFunc("bili_ticket_routines", "Ticket routines by state.", "state", func() map[string]float64 {
+		counts := make(map[string]float64)
+		for _, e := range ticketManager.Entries() {
+			counts[e.Routine.State().String()]++
+		}
+		return counts
+	})
+	metrics.NewGaugeFunc("bili_ticket_clock_offset_seconds", "NTP clock offset applied to the scheduler.", "", func() map[string]float64 {
+		return map[string]float64{"": schedulerManager.GetGlobalOffset().Seconds()}
+	})
+	metrics.NewGaugeFunc("bili_ticket_clock_sync_age_seconds", "Seconds since the clock offset was last synced, -1 if never.", "", func() map[string]float64 {
+		updated := schedulerManager.GetGlobalOffsetUpdatedAt()
+		if updated.IsZero() {
+			return map[string]float64{"": -1}
+		}
+		return map[string]float64{"": time.Since(updated).Seconds()}
+	})
+	metrics.NewGaugeFunc("bili_ticket_session_logged_in", "Whether the account was logged in at the last session check, -1 if never checked.", "", func() map[string]float64 {
+		checked, loggedIn, _ := sessionMonitor.Status()
+		switch {
+		case !checked:
+			return map[string]float64{"": -1}
+		case loggedIn:
+			return map[string]float64{"": 1}
+		}
+		return map[string]float64{"": 0}
+	})
 }
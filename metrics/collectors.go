@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 请求耗时的分桶, 抢票相关接口通常在几十到几百毫秒之间
+var requestBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 2, 5, 10}
+
+var (
+	attempts = NewCounterVec(
+		"bili_ticket_order_attempts_total",
+		"Order creation attempts by Bilibili API code.",
+		"code",
+	)
+	requestDuration = NewHistogramVec(
+		"bili_ticket_request_duration_seconds",
+		"Bilibili API request latency by endpoint.",
+		requestBuckets,
+		"endpoint",
+	)
+	cookieRefresh = NewCounterVec(
+		"bili_ticket_cookie_refresh_total",
+		"Cookie and bili_ticket refresh results.",
+		"kind", "result",
+	)
+	notifyFailures = NewCounterVec(
+		"bili_ticket_notify_failures_total",
+		"Notifications that failed to deliver.",
+		"type",
+	)
+)
+
+// AddAttempt 记录一次下单请求的返回码
+func AddAttempt(code int) {
+	attempts.Inc(strconv.Itoa(code))
+}
+
+// ObserveRequest 记录一次请求的耗时, 以 host + path 作为接口名, 不含查询参数
+func ObserveRequest(u *url.URL, d time.Duration) {
+	if u == nil {
+		return
+	}
+	requestDuration.Observe(d, u.Host+u.Path)
+}
+
+// AddCookieRefresh 记录一次刷新的结果, kind 为 cookie 或 bili_ticket
+func AddCookieRefresh(kind string, err error, refreshed bool) {
+	result := "skipped"
+	if err != nil {
+		result = "error"
+	} else if refreshed {
+		result = "refreshed"
+	}
+	cookieRefresh.Inc(kind, result)
+}
+
+func AddNotifyFailure(kind string) {
+	notifyFailures.Inc(kind)
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/utils"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+var logger = utils.GetLogger(global.GetLogger(), "metrics", nil)
+
+// Server 以 Prometheus 文本格式提供 /metrics
+// 指标中不包含任何账号信息, 因此不做鉴权, 需要时请只监听内网地址
+type Server struct {
+	address string
+	http    *http.Server
+}
+
+func NewServer(address string) *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", handle)
+	return &Server{
+		address: address,
+		http: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+func handle(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteTo(w)
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Metrics listening on %s", listener.Addr())
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown 等待正在处理的请求完成, ctx 结束时强制关闭剩余的连接
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+	if err != nil {
+		s.http.Close()
+	}
+	return err
+}
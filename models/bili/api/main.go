@@ -76,35 +76,55 @@ type TicketProjectInformationStruct struct {
 	HotProject  bool   `json:"hotProject"`
 	NeedContact bool   `json:"need_contact"`
 	IdBind      int    `json:"id_bind"` // I think it's similar to the `NeedContact`. 1 is force Real Name Authentication. 0 is not.
-	ScreenList  []struct {
+	SaleFlag    string `json:"sale_flag"`
+	// 纸质票需要填写收货地址
+	HasPaperTicket bool `json:"has_paper_ticket"`
+	VenueInfo      struct {
+		Name          string `json:"name"`
+		AddressDetail string `json:"address_detail"`
+	} `json:"venue_info"`
+	ScreenList []struct {
 		SaleFlag struct {
 			Number      int    `json:"number"`
 			DisplayName string `json:"display_name"`
 		} `json:"saleFlag"`
 		ScreenId     int64  `json:"id"`
 		StartTime    int64  `json:"start_time"`
+		EndTime      int64  `json:"end_time"`
 		Name         string `json:"name"`
 		Type         int    `json:"type"`
 		TicketType   int    `json:"ticket_type"`
 		ScreenType   int    `json:"screen_type"`
 		DeliveryType int    `json:"delivery_type"`
-		PickSeat     int    `json:"pick_seat"`
-		TicketList   []struct {
-			Price     int    `json:"price"`
-			Desc      string `json:"desc"`
-			SaleStart int64  `json:"saleStart"`
-			SaleEnd   int64  `json:"saleEnd"`
-			IsSale    int    `json:"is_sale"`
-			SkuId     int64  `json:"id"`
-			SaleFlag  struct {
-				Number      int    `json:"number"`
-				DisplayName string `json:"display_name"`
-			} `json:"sale_flag"`
-			ScreenName string `json:"screen_name"`
-		} `json:"ticket_list"`
+		// 纸质票的快递费, 单位为分, 每个订单收取一次, 不需要快递时为 0 或 -1
+		ExpressFee int               `json:"express_fee"`
+		PickSeat   int               `json:"pick_seat"`
+		TicketList []TicketSkuStruct `json:"ticket_list"`
 	} `json:"screen_list"`
 }
 
+type TicketSkuStruct struct {
+	Price     int    `json:"price"`
+	Desc      string `json:"desc"`
+	SaleStart int64  `json:"saleStart"`
+	SaleEnd   int64  `json:"saleEnd"`
+	IsSale    int    `json:"is_sale"`
+	SkuId     int64  `json:"id"`
+	SaleFlag  struct {
+		Number      int    `json:"number"`
+		DisplayName string `json:"display_name"`
+	} `json:"sale_flag"`
+	ScreenName string `json:"screen_name"`
+	// 每个账号的限购数量, 一般只有其中一个有值
+	StaticLimit  TicketLimitStruct `json:"static_limit"`
+	DynamicLimit TicketLimitStruct `json:"dynamic_limit"`
+}
+
+type TicketLimitStruct struct {
+	Num int    `json:"num"`
+	Msg string `json:"msg"`
+}
+
 type VoucherStruct struct {
 	Voucher string `json:"v_voucher"`
 }
@@ -2,6 +2,10 @@ package api
 
 import (
 	"bilibili-ticket-go/models/errors"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // ShowApiDataRoot 漫展API基类
@@ -58,21 +62,24 @@ type RequestTokenAndPTokenStruct struct {
 	Ptoken       string        `json:"ptoken"`
 }
 
+// BuyerListStruct 项目可选的购票人, MaxLimit 为一单最多的购票人数, 0 表示未限制
+type BuyerListStruct struct {
+	List     []BuyerStruct `json:"list"`
+	MaxLimit int           `json:"max_limit"`
+}
+
 type ConfirmStruct struct {
-	Count     int `json:"count"`
-	BuyerList struct {
-		List     []BuyerStruct `json:"list"`
-		MaxLimit int           `json:"max_limit"`
-	} `json:"buyerList"`
-	HotProject     bool   `json:"hotProject"`
-	OrderCreateUrl string `json:"orderCreateUrl"`
-	ProjectId      int    `json:"project_id"`
-	ProjectName    string `json:"project_name"`
-	ScreenId       int    `json:"screen_id"`
-	ScreenName     string `json:"screen_name"`
-	BuyerInfo      string `json:"buyer_info"`
-	ItemTotalMoney int    `json:"item_total_money"` // its value is the total price of all tickets, often equal to `pay_money`
-	PayMoney       int    `json:"pay_money"`
+	Count          int             `json:"count"`
+	BuyerList      BuyerListStruct `json:"buyerList"`
+	HotProject     bool            `json:"hotProject"`
+	OrderCreateUrl string          `json:"orderCreateUrl"`
+	ProjectId      int             `json:"project_id"`
+	ProjectName    string          `json:"project_name"`
+	ScreenId       int             `json:"screen_id"`
+	ScreenName     string          `json:"screen_name"`
+	BuyerInfo      string          `json:"buyer_info"`
+	ItemTotalMoney int             `json:"item_total_money"` // its value is the total price of all tickets, often equal to `pay_money`
+	PayMoney       int             `json:"pay_money"`
 	TicketInfo     struct {
 		Name  string `json:"name"`
 		Price int    `json:"price"`
@@ -107,6 +114,29 @@ type TicketOrderStruct struct {
 	PayMoney        int    `json:"pay_money"`
 }
 
+// DeliveryTypeExpress 场次的 delivery_type, 纸质票快递配送
+const DeliveryTypeExpress = 3
+
+type AddressListStruct struct {
+	AddrList []AddressStruct `json:"addr_list"`
+}
+
+type AddressStruct struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Prov    string `json:"prov"`
+	City    string `json:"city"`
+	Area    string `json:"area"`
+	Addr    string `json:"addr"`
+	Default int    `json:"def"`
+}
+
+// FullAddress 省市区和详细地址拼接后的完整地址
+func (a AddressStruct) FullAddress() string {
+	return a.Prov + a.City + a.Area + a.Addr
+}
+
 type BuyerNoSensitiveInfoApiStruct struct {
 	Vo struct {
 		List []BuyerNoSensitiveStruct `json:"list"`
@@ -125,3 +155,80 @@ type BuyerNoSensitiveStruct struct {
 	VerifyStatus int    `json:"verifyStatus"`
 	Status       int    `json:"status"`
 }
+
+// Verified 购票人已通过实名认证, 未认证的购票人无法下单
+func (b BuyerNoSensitiveStruct) Verified() bool {
+	return b.VerifyStatus == 1
+}
+
+// IdTypeIDCard 证件类型: 身份证
+const IdTypeIDCard = 0
+
+// ProjectListStruct 项目搜索 (search/list) 和项目列表 (project/listV2) 共用的返回结构
+type ProjectListStruct struct {
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"pagesize"`
+	Total    int                     `json:"total"`
+	NumPages int                     `json:"numPages"`
+	Result   []ProjectListItemStruct `json:"result"`
+}
+
+// ProjectListItemStruct 两个接口的字段名不完全一致, 搜索接口用 id/title, 列表接口用 project_id/project_name
+type ProjectListItemStruct struct {
+	Id          int64    `json:"id"`
+	ProjectId   int64    `json:"project_id"`
+	Title       string   `json:"title"`
+	ProjectName string   `json:"project_name"`
+	City        string   `json:"city"`
+	VenueName   string   `json:"venue_name"`
+	StartTime   ShowDate `json:"start_time"`
+	EndTime     ShowDate `json:"end_time"`
+	SaleFlag    string   `json:"sale_flag"`
+	PriceLow    int      `json:"price_low"`
+	PriceHigh   int      `json:"price_high"`
+}
+
+// ShowDate 会员购的日期字段有时是时间戳, 有时是 "2006-01-02" 这样的字符串
+type ShowDate struct {
+	time.Time
+}
+
+var showDateLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006.01.02"}
+
+func (d *ShowDate) UnmarshalJSON(bs []byte) error {
+	if string(bs) == "null" {
+		return nil
+	}
+	var ts int64
+	if err := json.Unmarshal(bs, &ts); err == nil {
+		if ts > 0 {
+			d.Time = time.Unix(ts, 0)
+		}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(bs, &s); err != nil {
+		return err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d.Time = time.Unix(ts, 0)
+		return nil
+	}
+	for _, layout := range showDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			d.Time = t
+			return nil
+		}
+	}
+	// 无法识别的格式 (例如 "2025-07-26 - 07-27") 只取前面的日期部分
+	if len(s) >= 10 {
+		if t, err := time.ParseInLocation("2006-01-02", s[:10], time.Local); err == nil {
+			d.Time = t
+		}
+	}
+	return nil
+}
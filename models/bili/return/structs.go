@@ -3,6 +3,7 @@ package _return
 import (
 	"bilibili-ticket-go/models/enums"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +21,14 @@ type TicketSkuScreenID struct {
 		Start time.Time
 		End   time.Time
 	}
+	Limit        int  // 每个账号的限购数量, 0 表示接口未返回
+	NeedDelivery bool // 纸质票, 下单时需要收货地址
+	ExpressFee   int  // 快递费, 单位为分, 只有纸质票收取
+}
+
+// PayMoney 下单时应付的总金额, 票价乘以数量再加上快递费
+func (t TicketSkuScreenID) PayMoney(count int) int {
+	return t.Price*count + t.ExpressFee
 }
 
 type RequestTokenAndPToken struct {
@@ -36,6 +45,29 @@ type ProjectInformation struct {
 	IsForceRealName bool
 	IsNeedContact   bool
 	ProjectName     string
+	SaleFlag        string
+	Venue           string
+	VenueAddress    string
+	Screens         []ScreenInformation
+}
+
+// ScreenInformation 一个场次及其下的所有票种
+type ScreenInformation struct {
+	ScreenID int64
+	Name     string
+	Start    time.Time
+	End      time.Time
+	SaleFlag string
+	Tickets  []TicketSkuScreenID
+}
+
+// Tickets 按场次顺序展开所有票种
+func (p *ProjectInformation) Tickets() []TicketSkuScreenID {
+	tickets := make([]TicketSkuScreenID, 0)
+	for _, s := range p.Screens {
+		tickets = append(tickets, s.Tickets...)
+	}
+	return tickets
 }
 
 type TicketBuyer struct {
@@ -72,3 +104,41 @@ func (buyer TicketBuyer) String() string {
 		return buyer.Name + " (ID: " + strconv.FormatInt(buyer.ID, 10) + ")"
 	}
 }
+
+// ProjectSummary 搜索结果中的一个项目, 价格单位为分
+type ProjectSummary struct {
+	ProjectID int64
+	Name      string
+	City      string
+	Venue     string
+	Start     time.Time
+	End       time.Time
+	SaleFlag  string
+	PriceLow  int
+	PriceHigh int
+}
+
+// Match 按城市 (包含即可) 和日期范围筛选, 零值表示不限制
+func (p ProjectSummary) Match(city string, from, to time.Time) bool {
+	if city != "" && !strings.Contains(p.City, city) {
+		return false
+	}
+	end := p.End
+	if end.IsZero() {
+		end = p.Start
+	}
+	if !from.IsZero() && !end.IsZero() && end.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !p.Start.IsZero() && p.Start.After(to) {
+		return false
+	}
+	return true
+}
+
+type ProjectSearchResult struct {
+	Page     int
+	NumPages int
+	Total    int
+	Projects []ProjectSummary
+}
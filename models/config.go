@@ -2,8 +2,12 @@ package models
 
 import (
 	"bilibili-ticket-go/bili"
+	"bilibili-ticket-go/global"
 	"bilibili-ticket-go/models/cookiejar"
+	"bilibili-ticket-go/utils"
 	"errors"
+	"path/filepath"
+	"sync"
 
 	"github.com/spf13/viper"
 )
@@ -25,19 +29,49 @@ type TicketSetting struct {
 	AutoStartBuying bool
 	NtpServer       string
 	Notification    Notification
+	WatchInterval   int // 余票监控的检查间隔, 单位为分钟
+	// SessionCheckInterval 登录状态的检查间隔, 单位为分钟
+	SessionCheckInterval int
+}
+
+// ControlSetting 本地 HTTP 控制接口
+// Address 为 host:port 时只允许回环地址, 也可以使用 unix:/path/to.sock
+type ControlSetting struct {
+	Enable  bool
+	Address string
+	Token   string
+}
+
+// MetricsSetting Prometheus 指标接口, 提供 /metrics
+type MetricsSetting struct {
+	Enable  bool
+	Address string
+}
+
+// LogSetting 日志文件的格式, text 与终端相同, json 每行一个 JSON 对象
+type LogSetting struct {
+	Format string
 }
 
 type Configuration struct {
-	Bilibili *Bilibili      `mapstructure:"bilibili"`
-	Ticket   *TicketSetting `mapstructure:"ticket"`
+	Bilibili *Bilibili       `mapstructure:"bilibili"`
+	Ticket   *TicketSetting  `mapstructure:"ticket"`
+	Control  *ControlSetting `mapstructure:"control"`
+	Metrics  *MetricsSetting `mapstructure:"metrics"`
+	Log      *LogSetting     `mapstructure:"log"`
 	viper    *viper.Viper
+	mutex    sync.Mutex
 }
 
 func NewConfiguration() (*Configuration, error) {
 	v := viper.New()
 	v.SetConfigName("config")
 	v.SetConfigType("json")
-	v.AddConfigPath(".")
+	v.AddConfigPath(global.ConfigDir())
+	if err := migrateFile(filepath.Join(global.ConfigDir(), "config.json"), ConfigSchemaVersion, configMigrations); err != nil {
+		return nil, err
+	}
+	v.SetDefault(schemaVersionKey, ConfigSchemaVersion)
 	v.SetDefault("bilibili",
 		&Bilibili{
 			Cookies: make([]cookiejar.CookieEntries, 0),
@@ -50,6 +84,22 @@ func NewConfiguration() (*Configuration, error) {
 			Notification: Notification{
 				Type: "none",
 			},
+			WatchInterval:        5,
+			SessionCheckInterval: 30,
+		})
+	v.SetDefault("control",
+		&ControlSetting{
+			Enable:  false,
+			Address: "127.0.0.1:17951",
+		})
+	v.SetDefault("metrics",
+		&MetricsSetting{
+			Enable:  false,
+			Address: "127.0.0.1:17952",
+		})
+	v.SetDefault("log",
+		&LogSetting{
+			Format: utils.LogFormatText,
 		})
 	err := v.SafeWriteConfig()
 	if err != nil {
@@ -71,11 +121,28 @@ func NewConfiguration() (*Configuration, error) {
 	return configuration, nil
 }
 
+// Save 原子地写回配置文件, 会话监控和界面可能同时调用
 func (c *Configuration) Save() error {
-	c.viper.Set("bilibili", &c.Bilibili)
-	err := c.viper.WriteConfig()
-	if err != nil {
-		return err
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.save()
+}
+
+// SaveSession 在同一把锁内更新 Cookie 和 refresh token 并写回配置文件
+// cookies 为 nil 或 refreshToken 为空时保留原来的值
+func (c *Configuration) SaveSession(cookies []cookiejar.CookieEntries, refreshToken string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cookies != nil {
+		c.Bilibili.Cookies = cookies
 	}
-	return nil
+	if refreshToken != "" {
+		c.Bilibili.RefreshToken = refreshToken
+	}
+	return c.save()
+}
+
+func (c *Configuration) save() error {
+	c.viper.Set("bilibili", &c.Bilibili)
+	return writeConfigAtomic(c.viper)
 }
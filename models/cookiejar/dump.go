@@ -9,6 +9,8 @@ import (
 )
 
 func (j *Jar) AllPersistentEntries() []CookieEntries {
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	var entries []CookieEntries
 	for _, submap := range j.entries {
 		for _, e := range submap {
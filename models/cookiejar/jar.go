@@ -73,6 +73,34 @@ type Jar struct {
 	// entries is a set of entries, keyed by their eTLD+1 and subkeyed by
 	// their name/domain/path.
 	entries map[string]map[string]CookieEntries
+
+	// onChange is called without holding mu after a persistent
+	// cookie has been added, removed or has changed its value. A new
+	// expiry alone does not count, servers refresh it on every response.
+	onChange func(names []string)
+}
+
+// SetChangeFunc sets the function called with the names of the
+// persistent cookies that have been added, removed or have changed
+// their value. It is called from the request that set the cookies,
+// so it should only block for changes that must be saved right away.
+func (j *Jar) SetChangeFunc(f func(names []string)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.onChange = f
+}
+
+// changed calls the change function. It must be called without holding mu.
+func (j *Jar) changed(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	j.mu.Lock()
+	f := j.onChange
+	j.mu.Unlock()
+	if f != nil {
+		f(names)
+	}
 }
 
 var noOptions Options
@@ -321,14 +349,18 @@ func (j *Jar) allCookies(now time.Time) []*http.Cookie {
 // specified by c.
 func (j *Jar) RemoveCookie(c *http.Cookie) {
 	j.mu.Lock()
-	defer j.mu.Unlock()
 	id := id(c.Domain, c.Path, c.Name)
 	key := jarKey(c.Domain, j.psList)
-	if e, ok := j.entries[key][id]; ok {
+	e, ok := j.entries[key][id]
+	if ok {
 		e.Value = ""
 		e.Expires = time.Now().Add(-1 * time.Second).Unix()
 		j.entries[key][id] = e
 	}
+	j.mu.Unlock()
+	if ok && e.Persistent {
+		j.changed([]string{e.Name})
+	}
 }
 
 func (j *Jar) defaultMerge(entries []CookieEntries) {
@@ -395,12 +427,14 @@ func (j *Jar) RemoveAllHost(host string) {
 	key := jarKey(host, j.psList)
 
 	j.mu.Lock()
-	defer j.mu.Unlock()
-
 	expired := time.Now().Add(-1 * time.Second)
 	submap := j.entries[key]
+	var names []string
 	for id, e := range submap {
 		if e.CanonicalHost == host {
+			if e.Persistent {
+				names = append(names, e.Name)
+			}
 			// Save some space by deleting the value when the cookie
 			// expires. We can't delete the cookie itself because then
 			// we wouldn't know that the cookie had expired when
@@ -410,15 +444,20 @@ func (j *Jar) RemoveAllHost(host string) {
 			submap[id] = e
 		}
 	}
+	j.mu.Unlock()
+	j.changed(names)
 }
 
 // RemoveAll removes all the cookies from the jar.
 func (j *Jar) RemoveAll() {
 	expired := time.Now().Add(-1 * time.Second)
 	j.mu.Lock()
-	defer j.mu.Unlock()
+	var names []string
 	for _, submap := range j.entries {
 		for id, e := range submap {
+			if e.Persistent {
+				names = append(names, e.Name)
+			}
 			// Save some space by deleting the value when the cookie
 			// expires. We can't delete the cookie itself because then
 			// we wouldn't know that the cookie had expired when
@@ -428,6 +467,8 @@ func (j *Jar) RemoveAll() {
 			submap[id] = e
 		}
 	}
+	j.mu.Unlock()
+	j.changed(names)
 }
 
 // SetCookies implements the SetCookies method of the http.CookieJar interface.
@@ -455,8 +496,7 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 	defPath := defaultPath(u.Path)
 
 	j.mu.Lock()
-	defer j.mu.Unlock()
-
+	var changed []string
 	submap := j.entries[key]
 	for _, cookie := range cookies {
 		e, err := j.newEntry(cookie, now, defPath, host)
@@ -469,14 +509,20 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 			submap = make(map[string]CookieEntries)
 			j.entries[key] = submap
 		}
-		if old, ok := submap[id]; ok {
+		old, ok := submap[id]
+		if ok {
 			e.Creation = old.Creation
 		} else {
 			e.Creation = now.Unix()
 		}
+		if (e.Persistent || old.Persistent) && (!ok || old.Value != e.Value || old.Persistent != e.Persistent) {
+			changed = append(changed, e.Name)
+		}
 		e.Updated = now.Unix()
 		submap[id] = e
 	}
+	j.mu.Unlock()
+	j.changed(changed)
 }
 
 // canonicalHost strips port from host if present and returns the canonicalized
@@ -2,17 +2,19 @@ package models
 
 import (
 	_return "bilibili-ticket-go/models/bili/return"
+	"bilibili-ticket-go/models/enums"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"errors"
 	"fmt"
-	"sync"
+	"reflect"
+	"strings"
 	"time"
-
-	"github.com/spf13/viper"
 )
 
 type TicketEntry struct {
+	// ID 添加时生成, 编辑任务后保持不变, 日志文件按 ID 命名
+	ID          string
 	Expire      int64
 	Start       int64
 	ProjectID   int64
@@ -21,136 +23,196 @@ type TicketEntry struct {
 	SkuName     string
 	ScreenID    int64
 	ScreenName  string
-	Buyer       _return.TicketBuyer
+	Buyer       _return.TicketBuyer   // 第一个购票人, 兼容只有一个购票人的旧数据
+	Buyers      []_return.TicketBuyer // 实名制项目一单多人时的全部购票人, 包含 Buyer
+	MaxPrice    int                   // 愿意支付的最高价格, 单位为分, 0 表示以开始抢票时票种的标价为准
+	AddressID   int64                 // 纸质票的收货地址, 0 表示未选择
+	// 首选票种不可售或超出最高价格时, 按顺序尝试的备选场次和票种, 最高价格同样适用
+	Alternatives []TicketAlternative
+	State        enums.RoutineState
+	LastResult   TicketResult
+	History      []TicketResult
 }
 
-func (t TicketEntry) String() string {
-	return t.ProjectName + " - " + t.SkuName + " - " + t.ScreenName + " - " + t.Buyer.String() + " (Expire: " + time.Unix(t.Expire, 0).Format("2006-01-02 15:04:05") + ";Start: " + time.Unix(t.Start, 0).Format("2006-01-02 15:04:05") + ")"
+// TicketAlternative 备选的场次和票种
+type TicketAlternative struct {
+	ScreenID   int64
+	ScreenName string
+	SkuID      int64
+	SkuName    string
 }
 
-func (t TicketEntry) Hash() string {
-	str := fmt.Sprintf(
-		"Buyer:BuyerType:%d,ID:%d,Name:%s,Tel:%s|Expire:%d|Start:%d|ProjectID:%d|ScreenID:%d|SkuID:%d",
-		t.Buyer.BuyerType, t.Buyer.ID, t.Buyer.Name, t.Buyer.Tel, t.Expire, t.Start, t.ProjectID, t.ScreenID, t.SkuID)
-	hash := sha256.Sum256([]byte(str))
-	return hex.EncodeToString(hash[:])
+func (a TicketAlternative) String() string {
+	return a.ScreenName + " - " + a.SkuName
 }
 
-func (t TicketEntry) Valid() bool {
-	return t.Expire > time.Now().Unix() && t.ProjectID > 0 && t.SkuID > 0 && t.ScreenID > 0 && t.Buyer.Valid()
-}
-
-type DataStorage struct {
-	TicketData           []TicketEntry `mapstructure:"ticket"`
-	ticketChangeCallback *func(storage *DataStorage, ticket TicketEntry)
-	viper                *viper.Viper
-	mutex                sync.Mutex
-}
-
-func NewDataStorage() (*DataStorage, error) {
-	v := viper.New()
-	v.SetConfigName("data")
-	v.SetConfigType("json")
-	v.AddConfigPath(".")
-	v.SetDefault("ticket",
-		[]TicketEntry{},
-	)
-	err := v.SafeWriteConfig()
-	if err != nil {
-		var configFileAlreadyExistsError viper.ConfigFileAlreadyExistsError
-		if !errors.As(err, &configFileAlreadyExistsError) {
-			return nil, err
-		}
-	}
-	err = v.ReadInConfig()
-	if err != nil {
-		return nil, err
+// 每个任务最多保留的历史记录数
+const maxTicketHistory = 20
+
+// TicketResult 任务最近一次结束时的结果
+type TicketResult struct {
+	State   enums.RoutineState
+	Code    int
+	Message string
+	OrderID int64
+	// 最后尝试的场次和票种, 成功时即为下单使用的首选或备选
+	ScreenID int64
+	SkuID    int64
+	Time     int64
+	Stats    TicketStats
+}
+
+// TicketStats 一次运行的请求统计摘要
+type TicketStats struct {
+	Attempts       int
+	TokenRefreshes int
+	Codes          map[string]int
+	Latency        map[string]LatencySummary
+}
+
+type LatencySummary struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+// AllBuyers 返回全部购票人, 旧数据只有 Buyer
+func (t TicketEntry) AllBuyers() []_return.TicketBuyer {
+	if len(t.Buyers) > 0 {
+		return t.Buyers
 	}
-	var configuration *DataStorage
-	err = v.Unmarshal(&configuration)
-	if err != nil {
-		return nil, err
+	return []_return.TicketBuyer{t.Buyer}
+}
+
+// Count 下单的票数, 非实名项目只填写一个联系人, 固定为 1
+func (t TicketEntry) Count() int {
+	if t.Buyer.BuyerType != enums.ForceRealName {
+		return 1
 	}
-	configuration.viper = v
-	return configuration, nil
+	return len(t.AllBuyers())
 }
 
-func (c *DataStorage) Save() error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.viper.Set("ticket", &c.TicketData)
-	err := c.viper.WriteConfig()
-	if err != nil {
-		return err
+// BuyerNames 以逗号分隔的购票人姓名
+func (t TicketEntry) BuyerNames() string {
+	buyers := t.AllBuyers()
+	names := make([]string, 0, len(buyers))
+	for _, b := range buyers {
+		names = append(names, b.Name)
 	}
-	return nil
+	return strings.Join(names, ", ")
+}
+
+// Choices 按优先级返回首选和全部备选
+func (t TicketEntry) Choices() []TicketAlternative {
+	choices := []TicketAlternative{{
+		ScreenID:   t.ScreenID,
+		ScreenName: t.ScreenName,
+		SkuID:      t.SkuID,
+		SkuName:    t.SkuName,
+	}}
+	return append(choices, t.Alternatives...)
 }
 
-func (c *DataStorage) AddTicket(data TicketEntry) {
-	ts := c.GetTickets()
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	for _, ticket := range ts {
-		if ticket.Buyer.Compare(data.Buyer) && ticket.ProjectID == data.ProjectID && ticket.SkuID == data.SkuID && ticket.ScreenID == data.ScreenID {
-			return
+// ChoiceIndex 返回场次和票种在 Choices 中的位置, 不存在时返回 -1
+func (t TicketEntry) ChoiceIndex(screenID int64, skuID int64) int {
+	for i, c := range t.Choices() {
+		if c.ScreenID == screenID && c.SkuID == skuID {
+			return i
 		}
 	}
-	c.TicketData = append(c.TicketData, data)
-	if c.ticketChangeCallback != nil {
-		go func() {
-			(*c.ticketChangeCallback)(c, data)
-		}()
+	return -1
+}
+
+func (t TicketEntry) String() string {
+	buyers := make([]string, 0)
+	for _, b := range t.AllBuyers() {
+		buyers = append(buyers, b.String())
 	}
+	return t.ProjectName + " - " + t.SkuName + " - " + t.ScreenName + " - " + strings.Join(buyers, ", ") + " (Expire: " + time.Unix(t.Expire, 0).Format("2006-01-02 15:04:05") + ";Start: " + time.Unix(t.Start, 0).Format("2006-01-02 15:04:05") + ")"
 }
 
-func (c *DataStorage) GetTickets() []TicketEntry {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	validTickets := make([]TicketEntry, 0)
-	for _, ticket := range c.TicketData {
-		if time.Unix(ticket.Expire, 0).After(time.Now()) {
-			validTickets = append(validTickets, ticket)
+// Hash 根据任务内容计算, 用于生成旧数据的 ID
+func (t TicketEntry) Hash() string {
+	str := fmt.Sprintf(
+		"Buyer:BuyerType:%d,ID:%d,Name:%s,Tel:%s|Expire:%d|Start:%d|ProjectID:%d|ScreenID:%d|SkuID:%d",
+		t.Buyer.BuyerType, t.Buyer.ID, t.Buyer.Name, t.Buyer.Tel, t.Expire, t.Start, t.ProjectID, t.ScreenID, t.SkuID)
+	// 只有一个购票人且没有备选时保持原来的格式, 已有任务的 hash 不变
+	if len(t.Buyers) > 1 {
+		for _, b := range t.Buyers[1:] {
+			str += fmt.Sprintf("|Buyer:BuyerType:%d,ID:%d,Name:%s,Tel:%s", b.BuyerType, b.ID, b.Name, b.Tel)
 		}
 	}
-	c.TicketData = validTickets
-	return validTickets
+	for _, a := range t.Alternatives {
+		str += fmt.Sprintf("|Alternative:ScreenID:%d,SkuID:%d", a.ScreenID, a.SkuID)
+	}
+	hash := sha256.Sum256([]byte(str))
+	return hex.EncodeToString(hash[:])
 }
 
-func (c *DataStorage) RemoveTicket(index int64) bool {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	if index < 0 || index >= int64(len(c.TicketData)) {
+// SameContent 比较除状态和结果以外的内容, 用于判断任务是否被编辑过
+func (t TicketEntry) SameContent(o TicketEntry) bool {
+	t.State, o.State = 0, 0
+	t.LastResult, o.LastResult = TicketResult{}, TicketResult{}
+	t.History, o.History = nil, nil
+	return reflect.DeepEqual(t, o)
+}
+
+func (t TicketEntry) Valid() bool {
+	if t.Expire <= time.Now().Unix() || t.ProjectID <= 0 || t.SkuID <= 0 || t.ScreenID <= 0 {
 		return false
 	}
-	old := c.TicketData[index]
-	c.TicketData = append((c.TicketData)[:index], (c.TicketData)[index+1:]...)
-	if c.ticketChangeCallback != nil {
-		go func() {
-			(*c.ticketChangeCallback)(c, old)
-		}()
+	for _, b := range t.AllBuyers() {
+		if !b.Valid() {
+			return false
+		}
+	}
+	for i, a := range t.Alternatives {
+		if a.ScreenID <= 0 || a.SkuID <= 0 || t.ChoiceIndex(a.ScreenID, a.SkuID) != i+1 {
+			return false
+		}
 	}
 	return true
 }
 
-func (c *DataStorage) RemoveTicketByHash(hash string) bool {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	for i, ticket := range c.TicketData {
-		if ticket.Hash() == hash {
-			old := ticket
-			c.TicketData = append((c.TicketData)[:i], (c.TicketData)[i+1:]...)
-			if c.ticketChangeCallback != nil {
-				go func() {
-					(*c.ticketChangeCallback)(c, old)
-				}()
-			}
-			return true
+func sameTicket(a, b TicketEntry) bool {
+	return sameBuyers(a, b) && a.ProjectID == b.ProjectID && a.SkuID == b.SkuID && a.ScreenID == b.ScreenID
+}
+
+func NewTicketID() string {
+	bs := make([]byte, 16)
+	_, _ = rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
+
+func sameBuyers(a, b TicketEntry) bool {
+	x, y := a.AllBuyers(), b.AllBuyers()
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !x[i].Compare(y[i]) {
+			return false
 		}
 	}
-	return false
+	return true
+}
+
+// WatchEntry 余票监控, 定期检查项目的票种状态, 变化时发送通知
+type WatchEntry struct {
+	ProjectID   int64
+	ProjectName string
+	SkuIDs      []int64 // 只关注这些票种的状态变化, 为空表示整个项目
+	Known       []WatchSku
+	LastCheck   int64
 }
-func (c *DataStorage) SetTicketChangeNotifyFunc(f *func(storage *DataStorage, ticket TicketEntry)) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.ticketChangeCallback = f
+
+// WatchSku 上一次检查时票种的状态
+type WatchSku struct {
+	ScreenID   int64
+	ScreenName string
+	SkuID      int64
+	SkuName    string
+	Flag       int
+	FlagName   string
 }
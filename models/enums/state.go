@@ -0,0 +1,65 @@
+package enums
+
+// RoutineState 抢票任务的生命周期状态
+type RoutineState int
+
+const (
+	StateQueued RoutineState = iota
+	StateScheduled
+	StateRunning
+	StatePaused
+	StateSucceeded
+	StateFailed
+	StateErrored
+	StateCancelled
+)
+
+var routineTransitions = map[RoutineState][]RoutineState{
+	StateQueued:    {StateScheduled, StateRunning, StatePaused, StateCancelled},
+	StateScheduled: {StateQueued, StateRunning, StatePaused, StateCancelled},
+	StateRunning:   {StateQueued, StatePaused, StateSucceeded, StateFailed, StateErrored, StateCancelled},
+	StatePaused:    {StateQueued, StateRunning, StateCancelled},
+	StateSucceeded: {},
+	StateFailed:    {StateQueued, StateRunning},
+	StateErrored:   {StateQueued, StateRunning},
+	StateCancelled: {StateQueued, StateRunning},
+}
+
+func (s RoutineState) String() string {
+	switch s {
+	case StateQueued:
+		return "Queued"
+	case StateScheduled:
+		return "Scheduled"
+	case StateRunning:
+		return "Running"
+	case StatePaused:
+		return "Paused"
+	case StateSucceeded:
+		return "Succeeded"
+	case StateFailed:
+		return "Failed"
+	case StateErrored:
+		return "Errored"
+	case StateCancelled:
+		return "Cancelled"
+	default:
+		return "Unknown"
+	}
+}
+
+// IsFinished 任务是否已经结束（不会再被调度）
+func (s RoutineState) IsFinished() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateErrored || s == StateCancelled
+}
+
+// CanTransitionTo 检查状态转换是否合法
+// Succeeded 是唯一不能离开的状态, 避免已经抢到的票被再次提交
+func (s RoutineState) CanTransitionTo(target RoutineState) bool {
+	for _, t := range routineTransitions[s] {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
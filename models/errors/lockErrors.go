@@ -0,0 +1,23 @@
+package errors
+
+import "fmt"
+
+// InstanceLockedError 数据目录已被另一个正在运行的实例锁定
+type InstanceLockedError struct {
+	Path string
+	PID  int
+}
+
+func NewInstanceLockedError(path string, pid int) *InstanceLockedError {
+	return &InstanceLockedError{
+		Path: path,
+		PID:  pid,
+	}
+}
+
+func (e *InstanceLockedError) Error() string {
+	if e.PID <= 0 {
+		return fmt.Sprintf("another instance is already running (lock file: %s)", e.Path)
+	}
+	return fmt.Sprintf("another instance is already running with PID %d (lock file: %s)", e.PID, e.Path)
+}
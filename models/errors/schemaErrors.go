@@ -0,0 +1,22 @@
+package errors
+
+import "fmt"
+
+// SchemaVersionError 文件由更新版本的程序写入, 继续读写会丢失数据
+type SchemaVersionError struct {
+	File      string
+	Version   int
+	Supported int
+}
+
+func NewSchemaVersionError(file string, version int, supported int) *SchemaVersionError {
+	return &SchemaVersionError{
+		File:      file,
+		Version:   version,
+		Supported: supported,
+	}
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("%s has schema version %d, but this build only supports up to version %d, please upgrade the program", e.File, e.Version, e.Supported)
+}
@@ -0,0 +1,37 @@
+package errors
+
+import "fmt"
+
+// EntryNotFoundError 存储中没有对应的任务或余票监控, Kind 为 ticket 或 watch
+type EntryNotFoundError struct {
+	Kind string
+	Key  string
+}
+
+func NewEntryNotFoundError(kind string, key string) *EntryNotFoundError {
+	return &EntryNotFoundError{
+		Kind: kind,
+		Key:  key,
+	}
+}
+
+func (e *EntryNotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Kind, e.Key)
+}
+
+// EntryExistsError 存储中已有相同的任务或余票监控
+type EntryExistsError struct {
+	Kind string
+	Key  string
+}
+
+func NewEntryExistsError(kind string, key string) *EntryExistsError {
+	return &EntryExistsError{
+		Kind: kind,
+		Key:  key,
+	}
+}
+
+func (e *EntryExistsError) Error() string {
+	return fmt.Sprintf("the same %s already exists: %s", e.Kind, e.Key)
+}
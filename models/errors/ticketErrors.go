@@ -20,6 +20,41 @@ func (ta *TicketEmptyContactError) Error() string {
 	return fmt.Sprintf("The ticket %s-%s-%s contact is empty", ta.ProjectID, ta.SkuID, ta.ScreenID)
 }
 
+// TicketMismatchError 下单前发现价格或票种与任务不一致
+type TicketMismatchError struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func NewTicketMismatchError(field string, expected string, actual string) *TicketMismatchError {
+	return &TicketMismatchError{
+		Field:    field,
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+func (e *TicketMismatchError) Error() string {
+	return fmt.Sprintf("%s mismatch: expected %s, got %s", e.Field, e.Expected, e.Actual)
+}
+
+type TicketPriceExceededError struct {
+	Price    int
+	MaxPrice int
+}
+
+func NewTicketPriceExceededError(price int, maxPrice int) *TicketPriceExceededError {
+	return &TicketPriceExceededError{
+		Price:    price,
+		MaxPrice: maxPrice,
+	}
+}
+
+func (e *TicketPriceExceededError) Error() string {
+	return fmt.Sprintf("price %.2f exceeds the maximum price %.2f", float64(e.Price)/100, float64(e.MaxPrice)/100)
+}
+
 type RoutineCreateError struct {
 	Message string
 }
@@ -33,3 +68,35 @@ func NewRoutineCreateError(message string) error {
 		Message: message,
 	}
 }
+
+type RoutineNotFoundError struct {
+	ID string
+}
+
+func NewRoutineNotFoundError(id string) *RoutineNotFoundError {
+	return &RoutineNotFoundError{
+		ID: id,
+	}
+}
+
+func (e *RoutineNotFoundError) Error() string {
+	return fmt.Sprintf("Ticket routine %s not found", e.ID)
+}
+
+type RoutineStateError struct {
+	ID     string
+	State  string
+	Action string
+}
+
+func NewRoutineStateError(id string, state string, action string) *RoutineStateError {
+	return &RoutineStateError{
+		ID:     id,
+		State:  state,
+		Action: action,
+	}
+}
+
+func (e *RoutineStateError) Error() string {
+	return fmt.Sprintf("Ticket routine %s can not %s in state %s", e.ID, e.Action, e.State)
+}
@@ -0,0 +1,170 @@
+package hooks
+
+import (
+	"bilibili-ticket-go/utils"
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogRecord 一条结构化的日志, 供日志页按级别, 组件和关键字过滤
+type LogRecord struct {
+	Seq       uint64
+	Time      time.Time
+	Level     logrus.Level
+	Component string
+	Message   string
+}
+
+// LogBuffer 保存最近的日志记录, 所有 logger 都可以挂上同一个 LogBuffer
+type LogBuffer struct {
+	mu       sync.RWMutex
+	records  []LogRecord
+	maxLines int
+	seq      uint64
+	onAppend func()
+}
+
+func NewLogBuffer(maxLines int) *LogBuffer {
+	return &LogBuffer{
+		records:  make([]LogRecord, 0, maxLines),
+		maxLines: maxLines,
+	}
+}
+
+func (h *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *LogBuffer) Fire(entry *logrus.Entry) error {
+	component, _ := entry.Data["name"].(string)
+	h.mu.Lock()
+	h.seq++
+	if len(h.records) >= h.maxLines {
+		h.records = h.records[1:]
+	}
+	h.records = append(h.records, LogRecord{
+		Seq:       h.seq,
+		Time:      entry.Time,
+		Level:     entry.Level,
+		Component: strings.ToLower(component),
+		Message:   utils.Redact(entry.Message),
+	})
+	f := h.onAppend
+	h.mu.Unlock()
+	if f != nil {
+		f()
+	}
+	return nil
+}
+
+// SetAppendFunc 每追加一条记录后调用, 回调可能在任意 goroutine 中执行, 不能阻塞
+func (h *LogBuffer) SetAppendFunc(f func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onAppend = f
+}
+
+// Since 返回序号大于 seq 的记录
+func (h *LogBuffer) Since(seq uint64) []LogRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	start := len(h.records)
+	for start > 0 && h.records[start-1].Seq > seq {
+		start--
+	}
+	records := make([]LogRecord, len(h.records)-start)
+	copy(records, h.records[start:])
+	return records
+}
+
+// maxRecordMessage 单条记录的续行超过这个长度后丢弃, 避免一条异常的记录占用过多内存
+const maxRecordMessage = 64 * 1024
+
+// ParseLogRecords 解析日志文件, 每行可以是 ColorfulFormatter 或 JSONFormatter 的格式, 不带前缀的行属于上一条记录
+// 只保留最后 maxLines 条记录, 解析时使用固定大小的环形缓冲区, 大文件也只占用固定的内存
+func ParseLogRecords(r io.Reader, maxLines int) ([]LogRecord, error) {
+	if maxLines <= 0 {
+		return nil, nil
+	}
+	ring := make([]LogRecord, 0, maxLines)
+	var total uint64
+	add := func(record LogRecord) {
+		total++
+		record.Seq = total
+		if len(ring) < maxLines {
+			ring = append(ring, record)
+		} else {
+			ring[(total-1)%uint64(maxLines)] = record
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := utils.ANSIStrip(scanner.Text())
+		if record, ok := parseJSONLogLine(line); ok {
+			add(record)
+			continue
+		}
+		parts := strings.SplitN(line, " | ", 4)
+		if len(parts) == 4 {
+			t, err := time.Parse(time.RFC3339, parts[0])
+			level, lerr := logrus.ParseLevel(parts[1])
+			if err == nil && lerr == nil {
+				add(LogRecord{
+					Time:      t,
+					Level:     level,
+					Component: parts[2],
+					Message:   parts[3],
+				})
+				continue
+			}
+		}
+		if total > 0 {
+			last := &ring[(total-1)%uint64(maxLines)]
+			if len(last.Message) < maxRecordMessage {
+				last.Message += "\n" + line
+			}
+		}
+	}
+	// 按时间顺序返回
+	start := int(total % uint64(maxLines))
+	if len(ring) < maxLines {
+		start = 0
+	}
+	records := make([]LogRecord, 0, len(ring))
+	records = append(records, ring[start:]...)
+	records = append(records, ring[:start]...)
+	return records, scanner.Err()
+}
+
+// parseJSONLogLine 解析 JSONFormatter 输出的一行
+func parseJSONLogLine(line string) (LogRecord, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return LogRecord{}, false
+	}
+	var fields struct {
+		Time  time.Time `json:"time"`
+		Level string    `json:"level"`
+		Name  string    `json:"name"`
+		Msg   string    `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return LogRecord{}, false
+	}
+	level, err := logrus.ParseLevel(fields.Level)
+	if err != nil {
+		return LogRecord{}, false
+	}
+	return LogRecord{
+		Time:      fields.Time,
+		Level:     level,
+		Component: fields.Name,
+		Message:   fields.Msg,
+	}, true
+}
@@ -0,0 +1,72 @@
+package hooks
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLogRecords(t *testing.T) {
+	input := "2026-01-02T15:04:05+08:00 | \x1b[34mINFO\x1b[0m | main | started\n" +
+		"2026-01-02T15:04:06+08:00 | WARNING | 0123456789a | request failed\n" +
+		"  continuation line\n" +
+		"{\"time\":\"2026-01-02T15:04:07.5+08:00\",\"level\":\"error\",\"name\":\"network\",\"msg\":\"timeout\",\"status\":2}\n"
+	records, err := ParseLogRecords(strings.NewReader(input), 2)
+	if err != nil {
+		t.Fatalf("ParseLogRecords() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("ParseLogRecords() len = %d, want 2", len(records))
+	}
+	if r := records[0]; r.Level != logrus.WarnLevel || r.Component != "0123456789a" || r.Message != "request failed\n  continuation line" {
+		t.Errorf("records[0] = %+v", r)
+	}
+	if r := records[1]; r.Level != logrus.ErrorLevel || r.Component != "network" {
+		t.Errorf("records[1] = %+v", r)
+	}
+}
+
+func TestParseLogRecordsKeepsLast(t *testing.T) {
+	var b strings.Builder
+	for i := range 10 {
+		fmt.Fprintf(&b, "2026-01-02T15:04:05+08:00 | INFO | main | record %d\n", i)
+	}
+	b.WriteString("  continuation of 9\n")
+	records, err := ParseLogRecords(strings.NewReader(b.String()), 3)
+	if err != nil {
+		t.Fatalf("ParseLogRecords() error = %v", err)
+	}
+	want := []string{"record 7", "record 8", "record 9\n  continuation of 9"}
+	if len(records) != len(want) {
+		t.Fatalf("ParseLogRecords() len = %d, want %d", len(records), len(want))
+	}
+	for i, r := range records {
+		if r.Message != want[i] || r.Seq != uint64(8+i) {
+			t.Errorf("records[%d] = %d %q, want %d %q", i, r.Seq, r.Message, 8+i, want[i])
+		}
+	}
+}
+
+func TestLogBufferSince(t *testing.T) {
+	b := NewLogBuffer(3)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(b)
+	entry := logger.WithField("name", "Main")
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		entry.Info(msg)
+	}
+	records := b.Since(0)
+	if len(records) != 3 || records[0].Message != "b" || records[2].Seq != 4 {
+		t.Fatalf("Since(0) = %+v, want the last 3 records", records)
+	}
+	if records[0].Component != "main" {
+		t.Errorf("Component = %q, want main", records[0].Component)
+	}
+	if got := b.Since(3); len(got) != 1 || got[0].Message != "d" {
+		t.Errorf("Since(3) = %+v, want [d]", got)
+	}
+}
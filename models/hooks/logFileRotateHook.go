@@ -22,13 +22,23 @@ func (h *LogFileRotateHook) Levels() []logrus.Level {
 }
 
 func (h *LogFileRotateHook) Fire(entry *logrus.Entry) error {
-	msg, err := entry.String()
-	msg = utils.ANSIStrip(msg)
-	if err != nil {
-		return err
+	var msg string
+	if formatter := utils.FileLogFormatter(); formatter != nil {
+		b, err := formatter.Format(entry)
+		if err != nil {
+			return err
+		}
+		msg = string(b)
+	} else {
+		var err error
+		msg, err = entry.String()
+		if err != nil {
+			return err
+		}
 	}
+	msg = utils.ANSIStrip(msg)
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
-	_, err = h.logger.Write([]byte(msg))
+	_, err := h.logger.Write([]byte(msg))
 	return err
 }
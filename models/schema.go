@@ -0,0 +1,126 @@
+package models
+
+import (
+	"bilibili-ticket-go/models/errors"
+	"bilibili-ticket-go/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 当前程序写出的 config.json 和 data.json 的版本, 没有 version 字段的旧文件视为版本 0
+// 修改 Bilibili, TicketEntry 等会写入文件的结构时, 提升版本并在下面登记迁移
+const (
+	ConfigSchemaVersion = 1
+	DataSchemaVersion   = 1
+)
+
+const schemaVersionKey = "version"
+
+// migration 把 From 版本的文件内容升级到 From+1, 键名与 viper 写出的一致, 均为小写
+type migration struct {
+	From        int
+	Description string
+	Apply       func(settings map[string]any) error
+}
+
+var configMigrations = []migration{
+	{
+		From:        0,
+		Description: "add schema version",
+		Apply:       func(map[string]any) error { return nil },
+	},
+}
+
+var dataMigrations = []migration{
+	{
+		From: 0,
+		// 旧任务缺少的 ID 在加载时根据 hash 补全, 这里不需要改动内容
+		Description: "add schema version",
+		Apply:       func(map[string]any) error { return nil },
+	},
+}
+
+// migrateFile 在 viper 读取之前升级文件, 升级前把原文件备份为 <path>.v<version>.bak
+// 文件不存在时不做任何事, 版本比程序新时返回 SchemaVersionError
+func migrateFile(path string, current int, migrations []migration) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// 保留数字的原始写法, 避免 ID 和时间戳经过 float64 后失真
+	settings := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err = decoder.Decode(&settings); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	version, err := schemaVersion(settings)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if version > current {
+		return errors.NewSchemaVersionError(path, version, current)
+	}
+	if version == current {
+		return nil
+	}
+	for v := version; v < current; v++ {
+		index := -1
+		for i, m := range migrations {
+			if m.From == v {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("migrate %s: no migration from version %d", path, v)
+		}
+		if err = migrations[index].Apply(settings); err != nil {
+			return fmt.Errorf("migrate %s from version %d (%s): %w", path, v, migrations[index].Description, err)
+		}
+		logger.Infof("Migrated %s from version %d: %s", path, v, migrations[index].Description)
+	}
+	settings[schemaVersionKey] = current
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err = utils.WriteFileAtomic(backup, b, 0o600); err != nil {
+		return fmt.Errorf("backup %s: %w", path, err)
+	}
+	out, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if st, err := os.Stat(path); err == nil {
+		perm = st.Mode().Perm()
+	}
+	return utils.WriteFileAtomic(path, out, perm)
+}
+
+// schemaVersion 读取文件中的版本, viper 不区分键名大小写, 这里也一样
+func schemaVersion(settings map[string]any) (int, error) {
+	for k, v := range settings {
+		if !strings.EqualFold(k, schemaVersionKey) {
+			continue
+		}
+		number, ok := v.(json.Number)
+		if !ok {
+			return 0, fmt.Errorf("invalid schema version %v", v)
+		}
+		n, err := strconv.Atoi(number.String())
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid schema version %v", v)
+		}
+		if k != schemaVersionKey {
+			delete(settings, k)
+			settings[schemaVersionKey] = n
+		}
+		return n, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bilibili-ticket-go/models/errors"
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestMigrateFile(t *testing.T) {
+	// 记录执行过的迁移, 每一步写入自己的标记, 用来检查链式迁移的顺序
+	var applied []int
+	step := func(from int) migration {
+		return migration{
+			From:        from,
+			Description: "test step",
+			Apply: func(settings map[string]any) error {
+				applied = append(applied, from)
+				settings["steps"] = append(toSlice(settings["steps"]), from)
+				return nil
+			},
+		}
+	}
+	chain := []migration{step(0), step(1), step(2)}
+	tests := []struct {
+		name        string
+		content     string // 空字符串表示文件不存在
+		current     int
+		migrations  []migration
+		wantErr     bool
+		wantVersion *errors.SchemaVersionError
+		wantApplied []int
+		wantBackup  string // 期望的备份文件后缀, 空字符串表示不应有备份
+	}{
+		{name: "missing file", current: 1, migrations: chain},
+		{name: "missing version", content: `{"ticket":[]}`, current: 1, migrations: chain, wantApplied: []int{0}, wantBackup: ".v0.bak"},
+		{name: "zero version", content: `{"version":0,"ticket":[]}`, current: 1, migrations: chain, wantApplied: []int{0}, wantBackup: ".v0.bak"},
+		{name: "current version", content: `{"version":1}`, current: 1, migrations: chain},
+		{name: "newer version", content: `{"version":5}`, current: 1, migrations: chain, wantErr: true, wantVersion: &errors.SchemaVersionError{Version: 5, Supported: 1}},
+		{name: "chained migrations", content: `{"Version":1,"id":12345678901234567890}`, current: 3, migrations: chain, wantApplied: []int{1, 2}, wantBackup: ".v1.bak"},
+		{name: "missing migration step", content: `{"version":0}`, current: 2, migrations: []migration{step(1)}, wantErr: true},
+		{name: "invalid version", content: `{"version":"one"}`, current: 1, migrations: chain, wantErr: true},
+		{name: "invalid json", content: `{"version":`, current: 1, migrations: chain, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied = nil
+			path := filepath.Join(t.TempDir(), "data.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := migrateFile(path, tt.current, tt.migrations)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("migrateFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantVersion != nil {
+				var versionErr *errors.SchemaVersionError
+				if !stderrors.As(err, &versionErr) || versionErr.Version != tt.wantVersion.Version || versionErr.Supported != tt.wantVersion.Supported {
+					t.Errorf("migrateFile() error = %v, want SchemaVersionError %d > %d", err, tt.wantVersion.Version, tt.wantVersion.Supported)
+				}
+			}
+			if !slices.Equal(applied, tt.wantApplied) {
+				t.Errorf("applied migrations = %v, want %v", applied, tt.wantApplied)
+			}
+			backups, _ := filepath.Glob(path + ".v*.bak")
+			if tt.wantBackup == "" {
+				if len(backups) != 0 {
+					t.Errorf("unexpected backups %v", backups)
+				}
+			} else {
+				b, err := os.ReadFile(path + tt.wantBackup)
+				if err != nil || string(b) != tt.content {
+					t.Errorf("backup %s = %q, %v, want the original content", tt.wantBackup, b, err)
+				}
+			}
+			got, readErr := os.ReadFile(path)
+			switch {
+			case tt.content == "":
+				if !os.IsNotExist(readErr) {
+					t.Errorf("migrateFile() created %s", path)
+				}
+			case tt.wantErr || tt.wantBackup == "":
+				if string(got) != tt.content {
+					t.Errorf("file changed to %s, want it untouched", got)
+				}
+			default:
+				checkMigratedFile(t, got, tt.current, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func checkMigratedFile(t *testing.T, content []byte, version int, steps []int) {
+	t.Helper()
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(content, &settings); err != nil {
+		t.Fatalf("migrated file is not JSON: %v", err)
+	}
+	if string(settings[schemaVersionKey]) != strconv.Itoa(version) {
+		t.Errorf("version = %s, want %d", settings[schemaVersionKey], version)
+	}
+	if _, ok := settings["Version"]; ok {
+		t.Error("the old Version key was kept")
+	}
+	var gotSteps []int
+	if err := json.Unmarshal(settings["steps"], &gotSteps); err != nil || !slices.Equal(gotSteps, steps) {
+		t.Errorf("steps = %s, want %v", settings["steps"], steps)
+	}
+	if id, ok := settings["id"]; ok && string(id) != "12345678901234567890" {
+		t.Errorf("id = %s, want the original digits", id)
+	}
+}
+
+func toSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
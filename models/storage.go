@@ -0,0 +1,400 @@
+package models
+
+import (
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/models/enums"
+	"bilibili-ticket-go/models/errors"
+	"bilibili-ticket-go/utils"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TicketChangeKind 任务队列变更的类型
+type TicketChangeKind int
+
+const (
+	TicketAdded TicketChangeKind = iota
+	TicketUpdated
+	TicketRemoved
+	TicketMoved
+)
+
+func (k TicketChangeKind) String() string {
+	switch k {
+	case TicketAdded:
+		return "Added"
+	case TicketUpdated:
+		return "Updated"
+	case TicketRemoved:
+		return "Removed"
+	case TicketMoved:
+		return "Moved"
+	}
+	return "Unknown"
+}
+
+// TicketChange 任务队列的一次变更, 状态和结果的更新不产生变更事件
+type TicketChange struct {
+	Kind   TicketChangeKind
+	Ticket TicketEntry
+}
+
+// dataFile data.json 的内容
+type dataFile struct {
+	Version int           `json:"version"`
+	Ticket  []TicketEntry `json:"ticket"`
+	Watch   []WatchEntry  `json:"watch"`
+}
+
+// DataStorage 保存任务队列和余票监控, 每次修改都在副本上进行, 原子地写入文件成功后才生效
+// 变更事件按提交顺序在同一个 goroutine 中依次通知订阅者
+type DataStorage struct {
+	path    string
+	mutex   sync.RWMutex
+	tickets []TicketEntry
+	watches []WatchEntry
+
+	events         *changeQueue
+	listenerMutex  sync.Mutex
+	listeners      map[int]func(TicketChange)
+	listenerID     int
+	dispatcherDone chan struct{}
+}
+
+func NewDataStorage() (*DataStorage, error) {
+	return OpenDataStorage(filepath.Join(global.StateDir(), "data.json"))
+}
+
+// OpenDataStorage 打开指定的数据文件, 不存在时创建
+func OpenDataStorage(path string) (*DataStorage, error) {
+	if err := migrateFile(path, DataSchemaVersion, dataMigrations); err != nil {
+		return nil, err
+	}
+	var file dataFile
+	b, err := os.ReadFile(path)
+	exists := err == nil
+	if exists {
+		if err = json.Unmarshal(b, &file); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	for i, t := range file.Ticket {
+		if t.ID == "" {
+			// 旧数据没有 ID, 取 hash 的前半部分, 日志文件名保持不变
+			file.Ticket[i].ID = t.Hash()[:32]
+		}
+	}
+	c := &DataStorage{
+		path:           path,
+		tickets:        file.Ticket,
+		watches:        file.Watch,
+		events:         newChangeQueue(),
+		listeners:      make(map[int]func(TicketChange)),
+		dispatcherDone: make(chan struct{}),
+	}
+	if c.tickets == nil {
+		c.tickets = make([]TicketEntry, 0)
+	}
+	if c.watches == nil {
+		c.watches = make([]WatchEntry, 0)
+	}
+	if !exists {
+		if err = c.write(c.tickets, c.watches); err != nil {
+			return nil, err
+		}
+	}
+	go c.dispatch()
+	return c, nil
+}
+
+// Save 把当前内容写回文件, 每次修改都会自动保存, 一般只在退出时调用
+func (c *DataStorage) Save() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.write(c.tickets, c.watches)
+}
+
+// Close 停止通知变更事件, 已提交的事件会先通知完
+func (c *DataStorage) Close() {
+	c.events.close()
+	<-c.dispatcherDone
+}
+
+// Subscribe 订阅任务队列的变更事件, 返回取消订阅的函数
+// 回调在同一个 goroutine 中按顺序执行, 可以读取存储, 但不应长时间阻塞
+func (c *DataStorage) Subscribe(f func(TicketChange)) func() {
+	c.listenerMutex.Lock()
+	defer c.listenerMutex.Unlock()
+	c.listenerID++
+	id := c.listenerID
+	c.listeners[id] = f
+	return func() {
+		c.listenerMutex.Lock()
+		defer c.listenerMutex.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+func (c *DataStorage) write(tickets []TicketEntry, watches []WatchEntry) error {
+	b, err := json.MarshalIndent(dataFile{
+		Version: DataSchemaVersion,
+		Ticket:  tickets,
+		Watch:   watches,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(c.path, b, 0o644)
+}
+
+// commit 写入修改后的副本, 成功后替换内存中的数据并放入变更事件, 调用前必须持有写锁
+func (c *DataStorage) commit(tickets []TicketEntry, watches []WatchEntry, changes ...TicketChange) error {
+	if err := c.write(tickets, watches); err != nil {
+		return err
+	}
+	c.tickets = tickets
+	c.watches = watches
+	c.events.push(changes...)
+	return nil
+}
+
+// validTickets 复制未过期的任务, 过期的任务在下一次修改时被清理
+func (c *DataStorage) validTickets() []TicketEntry {
+	now := time.Now()
+	tickets := make([]TicketEntry, 0, len(c.tickets))
+	for _, t := range c.tickets {
+		if time.Unix(t.Expire, 0).After(now) {
+			tickets = append(tickets, t)
+		}
+	}
+	return tickets
+}
+
+// AddTicket 添加任务, 已存在相同的任务时返回 EntryExistsError
+func (c *DataStorage) AddTicket(data TicketEntry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tickets := c.validTickets()
+	for _, t := range tickets {
+		if sameTicket(t, data) {
+			return errors.NewEntryExistsError("ticket", t.ID)
+		}
+	}
+	if data.ID == "" {
+		data.ID = NewTicketID()
+	}
+	tickets = append(tickets, data)
+	return c.commit(tickets, c.watches, TicketChange{Kind: TicketAdded, Ticket: data})
+}
+
+// GetTickets 返回未过期的任务
+func (c *DataStorage) GetTickets() []TicketEntry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.validTickets()
+}
+
+func (c *DataStorage) RemoveTicketByID(id string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tickets := c.validTickets()
+	index := slices.IndexFunc(tickets, func(t TicketEntry) bool { return t.ID == id })
+	if index < 0 {
+		return errors.NewEntryNotFoundError("ticket", id)
+	}
+	old := tickets[index]
+	tickets = slices.Delete(tickets, index, index+1)
+	return c.commit(tickets, c.watches, TicketChange{Kind: TicketRemoved, Ticket: old})
+}
+
+// UpdateTicketState 更新任务状态和结果, 不会产生变更事件
+// 任务结束时结果会追加到历史记录中
+func (c *DataStorage) UpdateTicketState(id string, state enums.RoutineState, result *TicketResult) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tickets := c.validTickets()
+	index := slices.IndexFunc(tickets, func(t TicketEntry) bool { return t.ID == id })
+	if index < 0 {
+		return errors.NewEntryNotFoundError("ticket", id)
+	}
+	tickets[index].State = state
+	if result != nil {
+		tickets[index].LastResult = *result
+		if state.IsFinished() {
+			history := append(slices.Clone(tickets[index].History), *result)
+			if len(history) > maxTicketHistory {
+				history = history[len(history)-maxTicketHistory:]
+			}
+			tickets[index].History = history
+		}
+	}
+	return c.commit(tickets, c.watches)
+}
+
+// UpdateTicket 修改任务内容, ID, 状态和历史记录保持不变
+// 修改后与其他任务重复时返回 EntryExistsError
+func (c *DataStorage) UpdateTicket(id string, data TicketEntry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tickets := c.validTickets()
+	index := -1
+	for i, t := range tickets {
+		if t.ID == id {
+			index = i
+		} else if sameTicket(t, data) {
+			return errors.NewEntryExistsError("ticket", t.ID)
+		}
+	}
+	if index < 0 {
+		return errors.NewEntryNotFoundError("ticket", id)
+	}
+	old := tickets[index]
+	data.ID = old.ID
+	data.State = old.State
+	data.LastResult = old.LastResult
+	data.History = old.History
+	tickets[index] = data
+	return c.commit(tickets, c.watches, TicketChange{Kind: TicketUpdated, Ticket: data})
+}
+
+// MoveTicket 将任务在列表中移动 offset 个位置, 超出范围时移动到两端
+func (c *DataStorage) MoveTicket(id string, offset int) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tickets := c.validTickets()
+	from := slices.IndexFunc(tickets, func(t TicketEntry) bool { return t.ID == id })
+	if from < 0 {
+		return errors.NewEntryNotFoundError("ticket", id)
+	}
+	to := min(max(from+offset, 0), len(tickets)-1)
+	if to == from {
+		return nil
+	}
+	ticket := tickets[from]
+	tickets = slices.Insert(slices.Delete(tickets, from, from+1), to, ticket)
+	return c.commit(tickets, c.watches, TicketChange{Kind: TicketMoved, Ticket: ticket})
+}
+
+// AddWatch 添加余票监控, 同一个项目只能有一个
+func (c *DataStorage) AddWatch(entry WatchEntry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := strconv.FormatInt(entry.ProjectID, 10)
+	if slices.ContainsFunc(c.watches, func(w WatchEntry) bool { return w.ProjectID == entry.ProjectID }) {
+		return errors.NewEntryExistsError("watch", key)
+	}
+	watches := append(slices.Clone(c.watches), entry)
+	return c.commit(c.tickets, watches)
+}
+
+func (c *DataStorage) RemoveWatch(projectID int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	index := slices.IndexFunc(c.watches, func(w WatchEntry) bool { return w.ProjectID == projectID })
+	if index < 0 {
+		return errors.NewEntryNotFoundError("watch", strconv.FormatInt(projectID, 10))
+	}
+	watches := slices.Delete(slices.Clone(c.watches), index, index+1)
+	return c.commit(c.tickets, watches)
+}
+
+func (c *DataStorage) GetWatches() []WatchEntry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return slices.Clone(c.watches)
+}
+
+// UpdateWatch 记录一次检查的结果
+func (c *DataStorage) UpdateWatch(projectID int64, name string, known []WatchSku, checked time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	index := slices.IndexFunc(c.watches, func(w WatchEntry) bool { return w.ProjectID == projectID })
+	if index < 0 {
+		return errors.NewEntryNotFoundError("watch", strconv.FormatInt(projectID, 10))
+	}
+	watches := slices.Clone(c.watches)
+	if name != "" {
+		watches[index].ProjectName = name
+	}
+	watches[index].Known = known
+	watches[index].LastCheck = checked.Unix()
+	return c.commit(c.tickets, watches)
+}
+
+// dispatch 依次把变更事件交给订阅者, 队列关闭并清空后返回
+func (c *DataStorage) dispatch() {
+	defer close(c.dispatcherDone)
+	for {
+		changes, ok := c.events.pop()
+		if !ok {
+			return
+		}
+		for _, change := range changes {
+			c.listenerMutex.Lock()
+			listeners := make([]func(TicketChange), 0, len(c.listeners))
+			for _, f := range c.listeners {
+				listeners = append(listeners, f)
+			}
+			c.listenerMutex.Unlock()
+			for _, f := range listeners {
+				f(change)
+			}
+		}
+	}
+}
+
+// changeQueue 无界的事件队列, push 不会阻塞持有存储锁的调用方
+type changeQueue struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	pending []TicketChange
+	closed  bool
+}
+
+func newChangeQueue() *changeQueue {
+	q := &changeQueue{}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *changeQueue) push(changes ...TicketChange) {
+	if len(changes) == 0 {
+		return
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return
+	}
+	q.pending = append(q.pending, changes...)
+	q.cond.Signal()
+}
+
+// pop 取出所有待通知的事件, 队列关闭且为空时返回 false
+func (q *changeQueue) pop() ([]TicketChange, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.pending) == 0 {
+		return nil, false
+	}
+	changes := q.pending
+	q.pending = nil
+	return changes, true
+}
+
+func (q *changeQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	_return "bilibili-ticket-go/models/bili/return"
+	"bilibili-ticket-go/models/enums"
+	"bilibili-ticket-go/models/errors"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTicket(skuID int64) TicketEntry {
+	return TicketEntry{
+		Expire:      time.Now().Add(time.Hour).Unix(),
+		Start:       time.Now().Add(time.Minute).Unix(),
+		ProjectID:   1,
+		ProjectName: "project",
+		ScreenID:    2,
+		SkuID:       skuID,
+		Buyer: _return.TicketBuyer{
+			BuyerType: enums.Ordinary,
+			Tel:       "13800000000",
+			Name:      "buyer",
+		},
+	}
+}
+
+func openTestStorage(t *testing.T) (*DataStorage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := OpenDataStorage(path)
+	if err != nil {
+		t.Fatalf("OpenDataStorage() error = %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s, path
+}
+
+func TestDataStorageConcurrentAdd(t *testing.T) {
+	s, _ := openTestStorage(t)
+	const workers = 16
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.AddTicket(newTestTicket(3))
+		}()
+	}
+	wg.Wait()
+	added := 0
+	for _, err := range errs {
+		var exists *errors.EntryExistsError
+		switch {
+		case err == nil:
+			added++
+		case !stderrors.As(err, &exists):
+			t.Errorf("AddTicket() unexpected error = %v", err)
+		}
+	}
+	if added != 1 {
+		t.Errorf("AddTicket() succeeded %d times, want 1", added)
+	}
+	if got := len(s.GetTickets()); got != 1 {
+		t.Errorf("GetTickets() len = %d, want 1", got)
+	}
+}
+
+func TestDataStorageConcurrentMutations(t *testing.T) {
+	s, _ := openTestStorage(t)
+	for i := range 8 {
+		if err := s.AddTicket(newTestTicket(int64(10 + i))); err != nil {
+			t.Fatalf("AddTicket() error = %v", err)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, ticket := range s.GetTickets() {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			s.UpdateTicketState(ticket.ID, enums.StateFailed, &TicketResult{Message: "failed"})
+		}()
+		go func() {
+			defer wg.Done()
+			s.MoveTicket(ticket.ID, -1)
+		}()
+		go func() {
+			defer wg.Done()
+			for _, t := range s.GetTickets() {
+				_ = t.History
+			}
+		}()
+	}
+	wg.Wait()
+	for _, ticket := range s.GetTickets() {
+		if ticket.State != enums.StateFailed || len(ticket.History) != 1 {
+			t.Errorf("ticket %s state = %s, history = %d, want Failed with 1 result", ticket.ID, ticket.State, len(ticket.History))
+		}
+	}
+}
+
+func TestDataStorageChangeOrder(t *testing.T) {
+	s, _ := openTestStorage(t)
+	var mutex sync.Mutex
+	var kinds []TicketChangeKind
+	done := make(chan struct{})
+	s.Subscribe(func(change TicketChange) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		kinds = append(kinds, change.Kind)
+		if len(kinds) == 5 {
+			close(done)
+		}
+	})
+	a, b := newTestTicket(3), newTestTicket(4)
+	a.ID, b.ID = NewTicketID(), NewTicketID()
+	steps := []func() error{
+		func() error { return s.AddTicket(a) },
+		func() error { return s.AddTicket(b) },
+		func() error { return s.MoveTicket(b.ID, -1) },
+		func() error {
+			edited := a
+			edited.MaxPrice = 100
+			return s.UpdateTicket(a.ID, edited)
+		},
+		func() error { return s.UpdateTicketState(a.ID, enums.StateRunning, nil) },
+		func() error { return s.RemoveTicketByID(a.ID) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d error = %v", i, err)
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change events")
+	}
+	want := []TicketChangeKind{TicketAdded, TicketAdded, TicketMoved, TicketUpdated, TicketRemoved}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("change events = %v, want %v", kinds, want)
+		}
+	}
+}
+
+func TestDataStorageRollbackOnWriteFailure(t *testing.T) {
+	s, path := openTestStorage(t)
+	if err := s.AddTicket(newTestTicket(3)); err != nil {
+		t.Fatalf("AddTicket() error = %v", err)
+	}
+	// 数据文件被替换为非空目录时重命名失败, 内存中的数据应保持不变
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "blocker"), 0o700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := s.AddTicket(newTestTicket(4)); err == nil {
+		t.Fatal("AddTicket() error = nil, want write error")
+	}
+	if got := len(s.GetTickets()); got != 1 {
+		t.Errorf("GetTickets() len = %d after failed write, want 1", got)
+	}
+}
+
+func TestDataStorageReload(t *testing.T) {
+	s, path := openTestStorage(t)
+	ticket := newTestTicket(3)
+	if err := s.AddTicket(ticket); err != nil {
+		t.Fatalf("AddTicket() error = %v", err)
+	}
+	if err := s.AddWatch(WatchEntry{ProjectID: 1, SkuIDs: []int64{3}}); err != nil {
+		t.Fatalf("AddWatch() error = %v", err)
+	}
+	reopened, err := OpenDataStorage(path)
+	if err != nil {
+		t.Fatalf("OpenDataStorage() error = %v", err)
+	}
+	defer reopened.Close()
+	tickets := reopened.GetTickets()
+	if len(tickets) != 1 || tickets[0].ID == "" || !tickets[0].SameContent(s.GetTickets()[0]) {
+		t.Errorf("reloaded tickets = %+v, want %+v", tickets, s.GetTickets())
+	}
+	if watches := reopened.GetWatches(); len(watches) != 1 || watches[0].SkuIDs[0] != 3 {
+		t.Errorf("reloaded watches = %+v", watches)
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"bilibili-ticket-go/global"
+	"bilibili-ticket-go/utils"
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+var logger = utils.GetLogger(global.GetLogger(), "storage", nil)
+
+// writeConfigAtomic 代替 viper.WriteConfig, 通过临时文件和重命名原子地写回配置文件
+func writeConfigAtomic(v *viper.Viper) error {
+	b, err := json.MarshalIndent(v.AllSettings(), "", "  ")
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if st, err := os.Stat(v.ConfigFileUsed()); err == nil {
+		perm = st.Mode().Perm()
+	}
+	return utils.WriteFileAtomic(v.ConfigFileUsed(), b, perm)
+}
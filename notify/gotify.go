@@ -1,6 +1,7 @@
 package notify
 
 import (
+	"bilibili-ticket-go/metrics"
 	"net/url"
 
 	"github.com/imroc/req/v3"
@@ -19,6 +20,18 @@ func NewGotify(token, endpoint string) *Gotify {
 }
 
 func (g *Gotify) Notify(msg string) bool {
+	return g.NotifyWithPriority(msg, PriorityNormal)
+}
+
+func (g *Gotify) NotifyWithPriority(msg string, priority Priority) bool {
+	if !g.send(msg, priority) {
+		metrics.AddNotifyFailure("gotify")
+		return false
+	}
+	return true
+}
+
+func (g *Gotify) send(msg string, priority Priority) bool {
 	result, err := url.JoinPath(g.endpoint, "message")
 	if err != nil {
 		logger.Warn(err)
@@ -26,7 +39,7 @@ func (g *Gotify) Notify(msg string) bool {
 	}
 	res, err := req.R().SetHeader("Authorization", "Bearer "+g.token).SetBodyJsonMarshal(map[string]any{
 		"message":  msg,
-		"priority": 8,
+		"priority": int(priority),
 		"title":    "Bili-Ticket-Go",
 	}).Post(result)
 	if err != nil {
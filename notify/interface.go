@@ -7,7 +7,17 @@ import (
 
 var logger = utils.GetLogger(global.GetLogger(), "notify", nil)
 
+// Priority 通知的优先级, 数值与 Gotify 一致
+type Priority int
+
+const (
+	PriorityNormal Priority = 8
+	// PriorityHigh 需要人立即处理的通知, 例如账号已退出登录
+	PriorityHigh Priority = 10
+)
+
 type Notify interface {
 	Notify(message string) bool
+	NotifyWithPriority(message string, priority Priority) bool
 	Test() bool
 }
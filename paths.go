@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bilibili-ticket-go/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	appDirName = "bilibili-ticket-go"
+	// dataDirEnv 与 --data-dir 作用相同, 命令行参数优先
+	dataDirEnv = "BILI_TICKET_DATA_DIR"
+)
+
+// resolveDirs 确定配置目录和状态目录
+// 指定了数据目录时两者相同, 否则使用 XDG_CONFIG_HOME 和 XDG_STATE_HOME,
+// 没有状态目录约定的平台上都放在系统的配置目录中
+func resolveDirs(dataDir string) (string, string, error) {
+	if dataDir == "" {
+		dataDir = os.Getenv(dataDirEnv)
+	}
+	if dataDir != "" {
+		dir, err := filepath.Abs(dataDir)
+		if err != nil {
+			return "", "", err
+		}
+		return dir, dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	config := filepath.Join(base, appDirName)
+	state := config
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		state = filepath.Join(dir, appDirName)
+	} else if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		state = filepath.Join(home, ".local", "state", appDirName)
+	}
+	return config, state, nil
+}
+
+// migratedMarker 迁移完成后写在状态目录中, 之后启动不再检查工作目录
+const migratedMarker = ".migrated"
+
+// migrateWorkingDir 把旧版本放在工作目录中的文件移动到新的目录, 只执行一次
+// 只移动能识别为本程序的文件, 目标已存在的文件不会被覆盖; 有文件移动失败时下次启动重试
+// 返回已移动的文件, 用于在日志初始化之后输出
+func migrateWorkingDir(configDir string, stateDir string) ([]string, error) {
+	marker := filepath.Join(stateDir, migratedMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return nil, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	targets := map[string]string{
+		"config.json": configDir,
+		"data.json":   stateDir,
+		"logs":        stateDir,
+	}
+	moved := make([]string, 0)
+	var errs []error
+	for _, name := range []string{"config.json", "data.json", "logs"} {
+		src := filepath.Join(wd, name)
+		dst := filepath.Join(targets[name], name)
+		if src == dst {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if !ownedPath(name, src) {
+			continue
+		}
+		if err := movePath(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("move %s to %s: %w", src, dst, err))
+			continue
+		}
+		moved = append(moved, fmt.Sprintf("%s -> %s", src, dst))
+	}
+	if len(errs) > 0 {
+		return moved, errors.Join(errs...)
+	}
+	if err := utils.WriteFileAtomic(marker, []byte(wd+"\n"), 0o600); err != nil {
+		return moved, fmt.Errorf("write migration marker: %w", err)
+	}
+	return moved, nil
+}
+
+// ownedPath 判断工作目录中的文件是否由本程序创建, 避免移走其他程序的 config.json 等文件
+func ownedPath(name string, path string) bool {
+	switch name {
+	case "config.json":
+		return hasJSONKeys(path, "bilibili", "ticket")
+	case "data.json":
+		return hasJSONKeys(path, "ticket")
+	case "logs":
+		for _, child := range []string{"latest.log", "tickets"} {
+			if _, err := os.Stat(filepath.Join(path, child)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasJSONKeys 文件是 JSON 对象且包含全部的键, 键名不区分大小写, 与 viper 一致
+func hasJSONKeys(path string, keys ...string) bool {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return false
+	}
+	found := make(map[string]bool, len(m))
+	for k := range m {
+		found[strings.ToLower(k)] = true
+	}
+	for _, k := range keys {
+		if !found[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// movePath 先复制到目标旁边的临时路径并校验内容, 再重命名为目标并删除源文件
+// 源文件和目标可能不在同一个文件系统上, 不直接重命名源文件
+func movePath(src string, dst string) error {
+	tmp := dst + ".migrating"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tmp, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		if err := copyFile(path, target); err != nil {
+			return err
+		}
+		return verifyCopy(path, target)
+	})
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, st.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// verifyCopy 重新读取两个文件比较哈希
+func verifyCopy(src string, dst string) error {
+	a, err := fileHash(src)
+	if err != nil {
+		return err
+	}
+	b, err := fileHash(dst)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) {
+		return fmt.Errorf("copy of %s does not match the source", src)
+	}
+	return nil
+}
+
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
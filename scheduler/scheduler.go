@@ -19,9 +19,10 @@ type ScheduledTask struct {
 
 // DynamicScheduler 动态调度器（支持全局偏移）
 type DynamicScheduler struct {
-	tasks        map[string]*ScheduledTask
-	globalOffset time.Duration // 全局偏移值
-	mutex        sync.RWMutex
+	tasks           map[string]*ScheduledTask
+	globalOffset    time.Duration // 全局偏移值
+	offsetUpdatedAt time.Time     // 最近一次设置偏移值的时间
+	mutex           sync.RWMutex
 }
 
 // NewDynamicScheduler 创建新的调度器
@@ -49,6 +50,7 @@ func (ds *DynamicScheduler) SetGlobalOffset(offset time.Duration) {
 
 	oldOffset := ds.globalOffset
 	ds.globalOffset = offset
+	ds.offsetUpdatedAt = time.Now()
 
 	// 重新调度所有运行中的任务
 	for _, task := range ds.tasks {
@@ -65,6 +67,13 @@ func (ds *DynamicScheduler) GetGlobalOffset() time.Duration {
 	return ds.globalOffset
 }
 
+// GetGlobalOffsetUpdatedAt 获取最近一次设置全局偏移值的时间, 从未设置时为零值
+func (ds *DynamicScheduler) GetGlobalOffsetUpdatedAt() time.Time {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+	return ds.offsetUpdatedAt
+}
+
 // AddTask 添加一次性定时任务
 func (ds *DynamicScheduler) AddTask(id string, targetTime time.Time, taskFunc func()) {
 	ds.mutex.Lock()
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout 退出时等待任务和后台服务停止的最长时间
+const shutdownTimeout = 10 * time.Second
+
+// servers 控制接口和指标接口等后台服务, 在 shutdown 中与任务共用同一个截止时间停止
+var servers []interface {
+	Shutdown(ctx context.Context) error
+}
+
+// handleSignals 收到 SIGINT/SIGTERM 时停止界面, 让 run 返回并执行退出流程
+// 之后再收到信号时按默认行为立即退出
+func handleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-ch
+		signal.Stop(ch)
+		logger.Warnf("Received %s, shutting down...", sig)
+		app.Stop()
+	}()
+}
+
+// shutdown 停止所有任务并保存配置和数据, 有任何一步失败时返回 false
+func shutdown() bool {
+	ok := true
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	// 先通知完已提交的队列变更, 之后不会再创建新任务
+	data.Close()
+	if err := ticketManager.Shutdown(ctx); err != nil {
+		logger.Errorf("Some ticket routines did not stop within %s: %v", shutdownTimeout, err)
+		ok = false
+	}
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Errorf("Failed to stop server: %v", err)
+			ok = false
+		}
+	}
+	if err := data.Save(); err != nil {
+		logger.Errorf("Failed to save data: %v", err)
+		ok = false
+	}
+	// 还没执行的延迟保存由这里的 saveSession 代替
+	sessionSaver.Stop()
+	if err := saveSession(); err != nil {
+		ok = false
+	}
+	if ok {
+		logger.Info("Shutdown complete.")
+	}
+	return ok
+}
+
+// ignoreHangup 守护进程不随启动它的终端关闭而退出
+func ignoreHangup() {
+	signal.Ignore(syscall.SIGHUP)
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"bilibili-ticket-go/tui/keyboard"
+	"bilibili-ticket-go/tui/primitives"
+
+	"github.com/rivo/tview"
+)
+
+// FormModal displays a form with one input field per label.
+// The modal is closed before done is called with the field values in the order of labels,
+// so done may open another modal. Cancel closes the modal without calling it.
+func FormModal(title string, labels []string, mount *primitives.Pages, done func(values []string), instance *keyboard.KeyboardCaptureInstance) {
+	instance.SetIsOpenModel(true)
+	form := tview.NewForm()
+	for _, label := range labels {
+		form.AddInputField(label, "", 30, nil, nil)
+	}
+	closeModal := func() {
+		instance.SetIsOpenModel(false)
+		mount.RemovePage("form")
+		mount.SetOthersClickableStat(0)
+	}
+	form.AddButton("Submit", func() {
+		values := make([]string, 0, len(labels))
+		for i := range labels {
+			values = append(values, form.GetFormItem(i).(*tview.InputField).GetText())
+		}
+		closeModal()
+		done(values)
+	})
+	form.AddButton("Cancel", closeModal)
+	form.SetCancelFunc(closeModal)
+	form.SetBorder(true).SetTitle(" " + title + " ")
+	width := 0
+	for _, label := range labels {
+		width = max(width, tview.TaggedStringWidth(label))
+	}
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(form, len(labels)*2+5, 0, true).
+			AddItem(nil, 0, 1, false), width+36, 0, true).
+		AddItem(nil, 0, 1, false)
+	mount.AddPage("form", modal, true, true)
+	mount.SetOthersClickableStat(1)
+}
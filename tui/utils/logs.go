@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bilibili-ticket-go/models/hooks"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/sirupsen/logrus"
+)
+
+// LogFilter 日志页的过滤条件, 空的 Component 和 Search 表示不过滤
+type LogFilter struct {
+	Level     logrus.Level
+	Component string
+	Search    string
+}
+
+func (f LogFilter) searchPattern() *regexp.Regexp {
+	if f.Search == "" {
+		return nil
+	}
+	return regexp.MustCompile("(?i)" + regexp.QuoteMeta(f.Search))
+}
+
+// FilterLogRecords 返回满足条件的日志, 按条件格式化为带颜色标签的文本, 关键字高亮显示
+func FilterLogRecords(records []hooks.LogRecord, filter LogFilter) string {
+	pattern := filter.searchPattern()
+	var b strings.Builder
+	for _, r := range records {
+		if r.Level > filter.Level {
+			continue
+		}
+		if filter.Component != "" && r.Component != filter.Component {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(r.Message) {
+			continue
+		}
+		b.WriteString(formatLogRecord(r, pattern))
+	}
+	return b.String()
+}
+
+func formatLogRecord(r hooks.LogRecord, pattern *regexp.Regexp) string {
+	var b strings.Builder
+	b.WriteString(r.Time.Format(time.RFC3339))
+	b.WriteString(" | [")
+	b.WriteString(logLevelColor(r.Level))
+	b.WriteString("]")
+	b.WriteString(strings.ToUpper(r.Level.String()))
+	b.WriteString("[-] | ")
+	b.WriteString(tview.Escape(r.Component))
+	b.WriteString(" | ")
+	last := 0
+	if pattern != nil {
+		for _, m := range pattern.FindAllStringIndex(r.Message, -1) {
+			b.WriteString(tview.Escape(r.Message[last:m[0]]))
+			b.WriteString("[black:yellow]")
+			b.WriteString(tview.Escape(r.Message[m[0]:m[1]]))
+			b.WriteString("[-:-]")
+			last = m[1]
+		}
+	}
+	b.WriteString(tview.Escape(r.Message[last:]))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// 与 ColorfulFormatter 的颜色保持一致
+func logLevelColor(level logrus.Level) string {
+	switch level {
+	case logrus.TraceLevel:
+		return "#a2cf6e"
+	case logrus.DebugLevel:
+		return "#4caf50"
+	case logrus.InfoLevel:
+		return "#2196f3"
+	case logrus.WarnLevel:
+		return "#ffeb3b"
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return "#f44336"
+	default:
+		return "#ffffff"
+	}
+}
+
+// LogComponents 返回日志中出现过的组件名, 已排序
+func LogComponents(records []hooks.LogRecord) []string {
+	components := make([]string, 0)
+	for _, r := range records {
+		if !slices.Contains(components, r.Component) {
+			components = append(components, r.Component)
+		}
+	}
+	slices.Sort(components)
+	return components
+}
+
+// ListLogFiles 返回目录及 tickets 子目录中的日志文件, 包括轮转后的旧文件, 最近修改的在前
+func ListLogFiles(dir string) []string {
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]logFile, 0)
+	for _, d := range []string{dir, filepath.Join(dir, "tickets")} {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".log") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, logFile{filepath.Join(d, e.Name()), info.ModTime()})
+		}
+	}
+	slices.SortFunc(files, func(a, b logFile) int {
+		return b.modTime.Compare(a.modTime)
+	})
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f.path)
+		if err != nil {
+			rel = f.path
+		}
+		paths = append(paths, rel)
+	}
+	return paths
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	r "bilibili-ticket-go/models/bili/return"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+const detailTimeLayout = "2006-01-02 15:04"
+
+// FormatProjectDetail 将项目信息格式化为购票页的详情文本, 票种按场次分组
+func FormatProjectDetail(info *r.ProjectInformation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]%s[-] (%s)\n", tview.Escape(info.ProjectName), info.ProjectID)
+	if info.Venue != "" {
+		fmt.Fprintf(&b, "Venue: %s", tview.Escape(info.Venue))
+		if info.VenueAddress != "" {
+			fmt.Fprintf(&b, " - %s", tview.Escape(info.VenueAddress))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Date: %s ~ %s\n", formatDetailTime(info.StartTime), formatDetailTime(info.EndTime))
+	fmt.Fprintf(&b, "Real name: %s, Contact: %s, Hot project: %s",
+		formatRequired(info.IsForceRealName), formatRequired(info.IsNeedContact), formatRequired(info.IsHotProject))
+	if info.SaleFlag != "" {
+		fmt.Fprintf(&b, ", Status: %s", tview.Escape(info.SaleFlag))
+	}
+	b.WriteString("\n")
+	for _, s := range info.Screens {
+		fmt.Fprintf(&b, "\n[green]%s[-]  %s", tview.Escape(s.Name), formatDetailTime(s.Start))
+		if s.SaleFlag != "" {
+			fmt.Fprintf(&b, "  [%s]", tview.Escape(s.SaleFlag))
+		}
+		b.WriteString("\n")
+		for _, t := range s.Tickets {
+			fmt.Fprintf(&b, "  %-16s ¥%-8s %s ~ %s  %s",
+				tview.Escape(t.Desc), FormatPrice(t.Price),
+				formatDetailTime(t.SaleStat.Start), formatDetailTime(t.SaleStat.End),
+				tview.Escape(t.Flags.DisplayName))
+			if t.Limit > 0 {
+				fmt.Fprintf(&b, "  limit %d/account", t.Limit)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// FormatPrice 将以分为单位的价格格式化为元
+func FormatPrice(cents int) string {
+	if cents%100 == 0 {
+		return fmt.Sprintf("%d", cents/100)
+	}
+	return fmt.Sprintf("%.2f", float64(cents)/100)
+}
+
+// ParsePrice 将以元为单位的输入转换为分
+func ParsePrice(text string) (int, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid price: %s", text)
+	}
+	return int(math.Round(v * 100)), nil
+}
+
+func formatDetailTime(t time.Time) string {
+	if t.IsZero() || t.Unix() <= 0 {
+		return "-"
+	}
+	return t.Format(detailTimeLayout)
+}
+
+func formatRequired(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bilibili-ticket-go/tui/keyboard"
+	"bilibili-ticket-go/tui/primitives"
+	"fmt"
+	"slices"
+
+	"github.com/rivo/tview"
+)
+
+// MultiSelectModal displays a list of options that can be toggled on and off.
+// selected holds the indexes that are checked initially. At most limit options can be
+// checked, limit <= 0 means no limit. Each checked option shows its position, and done
+// is called with the checked indexes in that order when the user confirms; cancelling
+// closes the modal without calling it.
+func MultiSelectModal(title string, options []string, selected []int, limit int, mount *primitives.Pages, done func(selected []int), instance *keyboard.KeyboardCaptureInstance) {
+	instance.SetIsOpenModel(true)
+	checked := make([]int, 0, len(selected))
+	for _, i := range selected {
+		if i >= 0 && i < len(options) && !slices.Contains(checked, i) {
+			checked = append(checked, i)
+		}
+	}
+	count := func() int {
+		return len(checked)
+	}
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+	updateTitle := func() {
+		if limit > 0 {
+			list.SetTitle(fmt.Sprintf(" %s (%d/%d) ", title, count(), limit))
+		} else {
+			list.SetTitle(fmt.Sprintf(" %s (%d) ", title, count()))
+		}
+	}
+	label := func(i int) string {
+		if pos := slices.Index(checked, i); pos >= 0 {
+			return fmt.Sprintf("[%d[] %s", pos+1, tview.Escape(options[i]))
+		}
+		return "[ [] " + tview.Escape(options[i])
+	}
+	closeModal := func() {
+		instance.SetIsOpenModel(false)
+		mount.RemovePage("select")
+		mount.SetOthersClickableStat(0)
+	}
+	for i := range options {
+		list.AddItem(label(i), "", 0, nil)
+	}
+	list.AddItem("Done", "", 0, nil)
+	list.AddItem("Cancel", "", 0, nil)
+	list.SetSelectedFunc(func(index int, _ string, _ string, _ rune) {
+		switch {
+		case index < len(options):
+			if pos := slices.Index(checked, index); pos >= 0 {
+				checked = slices.Delete(checked, pos, pos+1)
+			} else if limit <= 0 || count() < limit {
+				checked = append(checked, index)
+			}
+			// 取消选中后其他选项的序号会变化
+			for i := range options {
+				list.SetItemText(i, label(i), "")
+			}
+			updateTitle()
+		case index == len(options):
+			closeModal()
+			done(slices.Clone(checked))
+		default:
+			closeModal()
+		}
+	})
+	list.SetDoneFunc(closeModal)
+	updateTitle()
+	width := len(title) + 12
+	for _, o := range options {
+		width = max(width, tview.TaggedStringWidth(o)+8)
+	}
+	height := min(len(options)+4, 20)
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(list, height, 0, true).
+			AddItem(nil, 0, 1, false), min(width, 80), 0, true).
+		AddItem(nil, 0, 1, false)
+	mount.AddPage("select", modal, true, true)
+	mount.SetOthersClickableStat(1)
+}
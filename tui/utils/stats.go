@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"bilibili-ticket-go/bili/ticket"
+	"bilibili-ticket-go/models"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var statStages = []string{ticket.StagePrepare, ticket.StageConfirm, ticket.StageCreate}
+
+// FormatTicketStats 将任务统计格式化为统计面板的文本
+func FormatTicketStats(stats models.TicketStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Attempts: %d\n", stats.Attempts)
+	fmt.Fprintf(&b, "Token refreshes: %d\n\n", stats.TokenRefreshes)
+	b.WriteString("[yellow]Codes[-]\n")
+	codes := make([]string, 0, len(stats.Codes))
+	for code := range stats.Codes {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, " %-8s x%d\n", code, stats.Codes[code])
+	}
+	b.WriteString("\n[yellow]Latency p50/p90/p99[-]\n")
+	for _, stage := range statStages {
+		l, ok := stats.Latency[stage]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, " %-8s %s/%s/%s (n=%d)\n", stage, formatLatency(l.P50), formatLatency(l.P90), formatLatency(l.P99), l.Count)
+	}
+	return b.String()
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
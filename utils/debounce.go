@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer 把一段时间内的多次触发合并为一次调用, f 在后台 goroutine 中执行
+type Debouncer struct {
+	delay   time.Duration
+	f       func()
+	mutex   sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+func NewDebouncer(delay time.Duration, f func()) *Debouncer {
+	return &Debouncer{delay: delay, f: f}
+}
+
+// Trigger 在 delay 之后调用 f, 期间的其他触发不会推迟这次调用, 频繁触发时也至少每个 delay 调用一次
+func (d *Debouncer) Trigger() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.stopped || d.timer != nil {
+		return
+	}
+	d.timer = time.AfterFunc(d.delay, func() {
+		d.mutex.Lock()
+		d.timer = nil
+		d.mutex.Unlock()
+		d.f()
+	})
+}
+
+// Stop 取消还没执行的调用, 之后的触发都会被忽略, 返回是否有被取消的调用
+func (d *Debouncer) Stop() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.stopped = true
+	if d.timer == nil {
+		return false
+	}
+	pending := d.timer.Stop()
+	d.timer = nil
+	return pending
+}
@@ -20,3 +20,38 @@ func GetFileNameWithoutExt(path string) string {
 	nameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
 	return nameWithoutExt
 }
+
+// WriteFileAtomic 先写入同目录下的临时文件并同步到磁盘, 再重命名覆盖目标文件
+// 写入过程中崩溃或断电时, 目标文件要么是旧内容, 要么是完整的新内容
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	// 同步目录, 保证重命名本身也已落盘, 部分平台不支持时忽略
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ProjectLink 从项目 ID 或分享链接中解析出的信息, 场次和票种为 0 表示链接中没有指定
+type ProjectLink struct {
+	ProjectID int64
+	ScreenID  int64
+	SkuID     int64
+}
+
+var (
+	linkPattern   = regexp.MustCompile(`(?i)(?:https?://|bilibili://)[^\s"'<>，。【】]+`)
+	digitsPattern = regexp.MustCompile(`^\d+$`)
+)
+
+// 会员购的详情页所在的域名
+var projectHosts = []string{"show.bilibili.com", "mall.bilibili.com", "m.bilibili.com"}
+
+var (
+	projectIDKeys = []string{"id", "project_id", "projectId"}
+	screenIDKeys  = []string{"screen_id", "screenId"}
+	skuIDKeys     = []string{"sku_id", "skuId"}
+)
+
+// ExtractLink 从分享文本中取出第一个链接, 例如 "【xxx】 https://b23.tv/abcd"
+func ExtractLink(text string) string {
+	return linkPattern.FindString(text)
+}
+
+// IsShortLink 判断是否为需要跳转才能解析的短链接
+func IsShortLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "b23.tv" || strings.HasSuffix(host, ".b23.tv")
+}
+
+// ParseProjectLink 解析纯数字的项目 ID 或会员购详情页链接 (PC 和移动端)
+// 短链接需要先跳转, 见 Client.ResolveProjectLink
+func ParseProjectLink(text string) (ProjectLink, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ProjectLink{}, errors.New("project link is empty")
+	}
+	if digitsPattern.MatchString(text) {
+		id, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || id <= 0 {
+			return ProjectLink{}, fmt.Errorf("invalid project id: %s", text)
+		}
+		return ProjectLink{ProjectID: id}, nil
+	}
+	link := ExtractLink(text)
+	if link == "" {
+		return ProjectLink{}, fmt.Errorf("no link found in: %s", text)
+	}
+	if IsShortLink(link) {
+		return ProjectLink{}, fmt.Errorf("short link must be resolved first: %s", link)
+	}
+	return parseProjectURL(link, 0)
+}
+
+func parseProjectURL(link string, depth int) (ProjectLink, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ProjectLink{}, err
+	}
+	query := u.Query()
+	// 客户端的 bilibili://mall/web?url=... 以及跳转链接会把详情页放在 url 参数里
+	if inner := query.Get("url"); inner != "" && depth < 2 {
+		if l, err := parseProjectURL(inner, depth+1); err == nil {
+			return l, nil
+		}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ProjectLink{}, fmt.Errorf("not a project link: %s", link)
+	}
+	host := strings.ToLower(u.Hostname())
+	known := false
+	for _, h := range projectHosts {
+		if host == h {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return ProjectLink{}, fmt.Errorf("not a project link: %s", link)
+	}
+	// 移动端链接把参数放在 hash 路由里, 例如 /m/platform/home.html#/detail?id=123
+	if i := strings.Index(u.Fragment, "?"); i >= 0 {
+		if fragment, err := url.ParseQuery(u.Fragment[i+1:]); err == nil {
+			for k, v := range fragment {
+				if !query.Has(k) {
+					query[k] = v
+				}
+			}
+		}
+	}
+	var result ProjectLink
+	if result.ProjectID, err = queryInt(query, projectIDKeys); err != nil {
+		return ProjectLink{}, err
+	}
+	if result.ProjectID == 0 {
+		return ProjectLink{}, fmt.Errorf("no project id in link: %s", link)
+	}
+	if result.ScreenID, err = queryInt(query, screenIDKeys); err != nil {
+		return ProjectLink{}, err
+	}
+	if result.SkuID, err = queryInt(query, skuIDKeys); err != nil {
+		return ProjectLink{}, err
+	}
+	return result, nil
+}
+
+func queryInt(query url.Values, keys []string) (int64, error) {
+	for _, key := range keys {
+		v := query.Get(key)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return 0, fmt.Errorf("invalid %s: %s", key, v)
+		}
+		return id, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,155 @@
+package utils
+
+import "testing"
+
+func TestParseProjectLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ProjectLink
+		wantErr bool
+	}{
+		{
+			name:  "plain id",
+			input: "102194",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "plain id with spaces",
+			input: "  102194\n",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "pc detail",
+			input: "https://show.bilibili.com/platform/detail.html?id=102194&from=pc_ticketlist",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "pc detail with screen and sku",
+			input: "https://show.bilibili.com/platform/detail.html?id=102194&screen_id=180001&sku_id=500123",
+			want:  ProjectLink{ProjectID: 102194, ScreenID: 180001, SkuID: 500123},
+		},
+		{
+			name:  "mobile mall detail",
+			input: "https://mall.bilibili.com/neul/index.html?page=ticket_detail&noTitleBar=1&id=102194&from=share",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "mobile neul-next detail",
+			input: "https://mall.bilibili.com/neul-next/ticket/detail.html?id=102194&screenId=180001&skuId=500123",
+			want:  ProjectLink{ProjectID: 102194, ScreenID: 180001, SkuID: 500123},
+		},
+		{
+			name:  "mobile hash route",
+			input: "https://show.bilibili.com/m/platform/home.html#/detail?id=102194&project_id=102194",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "http scheme",
+			input: "http://show.bilibili.com/platform/detail.html?id=102194",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "project_id parameter",
+			input: "https://show.bilibili.com/platform/detail.html?project_id=102194",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "share text",
+			input: "【BilibiliWorld 2025】 https://show.bilibili.com/platform/detail.html?id=102194 快来看看吧",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:  "app scheme wrapping detail url",
+			input: "bilibili://mall/web?url=https%3A%2F%2Fmall.bilibili.com%2Fneul%2Findex.html%3Fpage%3Dticket_detail%26id%3D102194",
+			want:  ProjectLink{ProjectID: 102194},
+		},
+		{
+			name:    "short link needs resolving",
+			input:   "https://b23.tv/AbCd123",
+			wantErr: true,
+		},
+		{
+			name:    "other host",
+			input:   "https://www.bilibili.com/video/BV1xx411c7mD?id=102194",
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			input:   "https://show.bilibili.com/platform/home.html",
+			wantErr: true,
+		},
+		{
+			name:    "invalid id",
+			input:   "https://show.bilibili.com/platform/detail.html?id=abc",
+			wantErr: true,
+		},
+		{
+			name:    "zero id",
+			input:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "plain text",
+			input:   "hello",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProjectLink(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseProjectLink(%q) = %+v, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProjectLink(%q) error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseProjectLink(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsShortLink(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"https://b23.tv/AbCd123", true},
+		{"http://b23.tv/AbCd123", true},
+		{"https://B23.TV/AbCd123", true},
+		{"https://show.bilibili.com/platform/detail.html?id=1", false},
+		{"102194", false},
+	}
+	for _, tt := range tests {
+		if got := IsShortLink(tt.input); got != tt.want {
+			t.Errorf("IsShortLink(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestExtractLink(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"【项目】 https://b23.tv/AbCd123 快来看看", "https://b23.tv/AbCd123"},
+		{"https://show.bilibili.com/platform/detail.html?id=1", "https://show.bilibili.com/platform/detail.html?id=1"},
+		{"【项目】https://b23.tv/AbCd123，快来", "https://b23.tv/AbCd123"},
+		{"no link here", ""},
+	}
+	for _, tt := range tests {
+		if got := ExtractLink(tt.input); got != tt.want {
+			t.Errorf("ExtractLink(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package utils
+
+import (
+	"net"
+	"syscall"
+)
+
+// ListenUnix 监听 unix socket, 创建时屏蔽组和其他用户的权限, 避免 chmod 之前被其他用户连接
+// umask 是进程级的, 期间其他 goroutine 创建的文件权限只会更严格
+func ListenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0o177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,8 @@
+package utils
+
+import "net"
+
+// ListenUnix Windows 上 socket 文件的访问权限由所在目录的 ACL 决定
+func ListenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bilibili-ticket-go/models/errors"
+	stderrors "errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	errLockHeld     = stderrors.New("lock is held by another process")
+	errLockReplaced = stderrors.New("lock file was replaced while locking")
+)
+
+// InstanceLock 进程存活期间一直持有的咨询锁, 锁文件中记录持有者的 PID
+// 锁由操作系统在进程退出时释放, 所以崩溃后留下的锁文件不会阻止下次启动
+type InstanceLock struct {
+	file *os.File
+	// StalePID 上次没有正常退出的实例留下的 PID, 没有时为 0
+	StalePID int
+}
+
+// lockAttempts 锁文件在加锁期间被替换时的重试次数
+const lockAttempts = 10
+
+// AcquireInstanceLock 锁定 path, 已被其他实例持有时返回 InstanceLockedError
+func AcquireInstanceLock(path string) (*InstanceLock, error) {
+	for range lockAttempts {
+		lock, err := tryInstanceLock(path)
+		if err != errLockReplaced {
+			return lock, err
+		}
+	}
+	return nil, errLockReplaced
+}
+
+func tryInstanceLock(path string) (*InstanceLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	pid := readLockPID(f)
+	if err = lockFile(f); err != nil {
+		f.Close()
+		if err == errLockHeld {
+			return nil, errors.NewInstanceLockedError(path, pid)
+		}
+		return nil, err
+	}
+	// 打开之后, 加锁之前, 上一个实例可能已经删除了这个文件, 另一个实例又创建了新的锁文件
+	// 锁住的不再是 path 上的文件时重新打开
+	if !sameFile(f, path) {
+		unlockFile(f)
+		f.Close()
+		return nil, errLockReplaced
+	}
+	lock := &InstanceLock{file: f}
+	if pid > 0 && pid != os.Getpid() {
+		lock.StalePID = pid
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+func sameFile(f *os.File, path string) bool {
+	locked, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(locked, current)
+}
+
+// Release 释放锁并删除锁文件
+func (l *InstanceLock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+	name := l.file.Name()
+	// 先删除再解锁, 避免删掉下一个实例刚创建的锁文件
+	// 在删除前打开了旧文件的实例加锁后会发现文件已被替换并重试
+	os.Remove(name)
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func readLockPID(f *os.File) int {
+	b, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bilibili-ticket-go/models/errors"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstanceLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instance.lock")
+	lock, err := AcquireInstanceLock(path)
+	if err != nil {
+		t.Fatalf("AcquireInstanceLock() error = %v", err)
+	}
+	var locked *errors.InstanceLockedError
+	if _, err := AcquireInstanceLock(path); !stderrors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("second AcquireInstanceLock() error = %v, want InstanceLockedError with our PID", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	lock, err = AcquireInstanceLock(path)
+	if err != nil {
+		t.Fatalf("AcquireInstanceLock() after Release error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestSameFileAfterReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instance.lock")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !sameFile(f, path) {
+		t.Fatal("sameFile() = false for the open file")
+	}
+	// 模拟上一个实例释放时删除了文件, 另一个实例又创建了新的锁文件
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if sameFile(f, path) {
+		t.Error("sameFile() = true after the path was replaced")
+	}
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/fatih/color"
@@ -51,7 +54,96 @@ func (f *ColorfulFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 			entry.Time.Format(time.RFC3339),
 			levelColor.Sprint(strings.ToUpper(entry.Level.String())),
 			strings.ToLower(entry.Data["name"].(string)),
-			entry.Message,
+			Redact(entry.Message),
 		),
 	), nil
 }
+
+// 日志文件的格式, 终端和日志页始终使用 ColorfulFormatter
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+var fileFormatter atomic.Pointer[logrus.Formatter]
+
+// SetFileLogFormat 设置 latest.log 和任务日志文件的格式, 空字符串等同于 text
+func SetFileLogFormat(format string) error {
+	var formatter logrus.Formatter
+	switch strings.ToLower(format) {
+	case "", LogFormatText:
+		formatter = &ColorfulFormatter{}
+	case LogFormatJSON:
+		formatter = &JSONFormatter{}
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+	fileFormatter.Store(&formatter)
+	return nil
+}
+
+// FileLogFormatter 返回日志文件使用的格式, 未设置时为 nil, 使用 logger 自己的格式
+func FileLogFormatter() logrus.Formatter {
+	if f := fileFormatter.Load(); f != nil {
+		return *f
+	}
+	return nil
+}
+
+// JSONFormatter 每条日志输出一行 JSON, 便于 jq 和 Loki/ELK 处理
+// 固定字段为 time, level, name 和 msg, 其他字段(如 status, bili)原样保留为 JSON, 字符串同样经过脱敏
+type JSONFormatter struct {
+}
+
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+4)
+	for k, v := range entry.Data {
+		if k == "name" || k == "parts" {
+			continue
+		}
+		switch k {
+		case "time", "level", "msg":
+			k = "fields." + k
+		}
+		data[k] = redactValue(v)
+	}
+	data["time"] = entry.Time.Format(time.RFC3339Nano)
+	data["level"] = entry.Level.String()
+	name, _ := entry.Data["name"].(string)
+	data["name"] = strings.ToLower(name)
+	data["msg"] = Redact(entry.Message)
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal log entry: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// redactValue 对字段中的字符串脱敏, 无法编码为 JSON 的值转为字符串
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return Redact(v)
+	case error:
+		return Redact(v.Error())
+	case logrus.Fields:
+		fields := make(map[string]any, len(v))
+		for k, x := range v {
+			fields[k] = redactValue(x)
+		}
+		return fields
+	case map[string]any:
+		fields := make(map[string]any, len(v))
+		for k, x := range v {
+			fields[k] = redactValue(x)
+		}
+		return fields
+	default:
+		if _, err := json.Marshal(v); err != nil {
+			return Redact(fmt.Sprint(v))
+		}
+		return v
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	entry := &logrus.Entry{
+		Time:    time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		Level:   logrus.InfoLevel,
+		Message: "SESSDATA=abcdef order placed",
+		Data: logrus.Fields{
+			"name":   "0123456789A",
+			"parts":  (*LogAdditionalParts)(nil),
+			"status": 1,
+			"bili": logrus.Fields{
+				"code":  0,
+				"phone": "13812345678",
+			},
+			"error": errors.New("failed"),
+		},
+	}
+	b, err := (&JSONFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, b)
+	}
+	if got["time"] != "2026-01-02T15:04:05Z" || got["level"] != "info" || got["name"] != "0123456789a" {
+		t.Errorf("fixed fields = %v", got)
+	}
+	if got["msg"] != "SESSDATA=****** order placed" {
+		t.Errorf("msg = %v, want redacted", got["msg"])
+	}
+	if _, ok := got["parts"]; ok {
+		t.Error("parts should be omitted")
+	}
+	if got["status"] != float64(1) || got["error"] != "failed" {
+		t.Errorf("status = %v, error = %v", got["status"], got["error"])
+	}
+	bili, ok := got["bili"].(map[string]any)
+	if !ok || bili["code"] != float64(0) || bili["phone"] != MaskPhone("13812345678") {
+		t.Errorf("bili = %v, want nested object with redacted phone", got["bili"])
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "strings"
+
+// MaskMiddle 只保留开头 head 个和结尾 tail 个字符, 其余替换为 *, 太短时全部替换
+func MaskMiddle(s string, head int, tail int) string {
+	runes := []rune(s)
+	if len(runes) <= head+tail {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:head]) + strings.Repeat("*", len(runes)-head-tail) + string(runes[len(runes)-tail:])
+}
+
+// MaskIDCard 证件号只保留前 3 位和后 4 位
+func MaskIDCard(id string) string {
+	return MaskMiddle(id, 3, 4)
+}
+
+// MaskPhone 手机号只保留前 3 位和后 4 位
+func MaskPhone(tel string) string {
+	return MaskMiddle(tel, 3, 4)
+}
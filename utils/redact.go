@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 登录凭证, 形如 SESSDATA=xxx, "refresh_token":"xxx", RefreshToken:xxx
+	secretPattern = regexp.MustCompile(`(?i)\b(SESSDATA|bili_jct|csrf|refresh_?token|refresh_csrf|ptoken|ctoken|access_?key)(["']?\s*[:=]\s*["']?)([^\s;&"',}\]]+)`)
+	// 18 位身份证号
+	idCardPattern = regexp.MustCompile(`\b\d{17}[\dXx]\b`)
+	// 11 位手机号
+	phonePattern = regexp.MustCompile(`\b1[3-9]\d{9}\b`)
+)
+
+// redactionDisabledUntil 暂时关闭脱敏的截止时间(UnixNano), 0 表示开启脱敏, -1 表示直到程序退出
+var redactionDisabledUntil atomic.Int64
+
+// DisableRedaction 暂时关闭日志脱敏, 用于排查问题, d <= 0 时直到程序退出
+func DisableRedaction(d time.Duration) {
+	if d <= 0 {
+		redactionDisabledUntil.Store(-1)
+		return
+	}
+	redactionDisabledUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+func EnableRedaction() {
+	redactionDisabledUntil.Store(0)
+}
+
+func RedactionEnabled() bool {
+	until := redactionDisabledUntil.Load()
+	return until == 0 || (until > 0 && time.Now().UnixNano() >= until)
+}
+
+// Redact 隐藏文本中的登录凭证, 手机号和身份证号, 所有日志输出前都会经过这里
+func Redact(s string) string {
+	if !RedactionEnabled() {
+		return s
+	}
+	s = secretPattern.ReplaceAllString(s, "${1}${2}******")
+	s = idCardPattern.ReplaceAllStringFunc(s, MaskIDCard)
+	return phonePattern.ReplaceAllStringFunc(s, MaskPhone)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "cookie header",
+			input: "Cookie: SESSDATA=abc%2C123; bili_jct=0123456789abcdef; DedeUserID=42",
+			want:  "Cookie: SESSDATA=******; bili_jct=******; DedeUserID=42",
+		},
+		{
+			name:  "query string",
+			input: "POST https://passport.bilibili.com/x/passport-login/web/cookie/refresh?csrf=0123abcd&refresh_token=ffee&source=main_web",
+			want:  "POST https://passport.bilibili.com/x/passport-login/web/cookie/refresh?csrf=******&refresh_token=******&source=main_web",
+		},
+		{
+			name:  "json body",
+			input: `{"ptoken":"p-1","ctoken":"c-2","refresh_token":"r-3"}`,
+			want:  `{"ptoken":"******","ctoken":"******","refresh_token":"******"}`,
+		},
+		{
+			name:  "struct dump",
+			input: "{RefreshToken:abcdef Tel:13800001234 ID:11010119900307123X}",
+			want:  "{RefreshToken:****** Tel:138****1234 ID:110***********123X}",
+		},
+		{
+			name:  "ordinary numbers",
+			input: "project 102194 sku 500123 order 2025071912345678901",
+			want:  "project 102194 sku 500123 order 2025071912345678901",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.input); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisableRedaction(t *testing.T) {
+	defer EnableRedaction()
+	input := "bili_jct=secret"
+	DisableRedaction(time.Hour)
+	if got := Redact(input); got != input {
+		t.Errorf("Redact() with redaction disabled = %q, want %q", got, input)
+	}
+	DisableRedaction(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if got := Redact(input); got == input {
+		t.Errorf("Redact() after the disable period = %q, want redacted", got)
+	}
+}